@@ -3,39 +3,191 @@
 
 package avcodec
 
-//#cgo pkg-config: libavcodec
-//#include <libavcodec/avcodec.h>
+/*
+#cgo pkg-config: libavcodec
+#include <libavcodec/avcodec.h>
+#if LIBAVCODEC_VERSION_INT >= AV_VERSION_INT(58, 87, 100)
+#include <libavcodec/bsf.h>
+#endif
+#include <stdlib.h>
+*/
 import "C"
 import (
-//	"unsafe"
+	"fmt"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
 )
 
-//Register a bitstream filter.
-/*func (b *BitStreamFilter) AvRegisterBitstreamFilter() {
-	C.av_register_bitstream_filter((*C.struct_AVBitStreamFilter)(b))
+type BSFContext C.struct_AVBSFContext
+
+//Return the bitstream filter with the given name, or nil if there is none.
+func AvBsfGetByName(n string) *BitStreamFilter {
+	cn := C.CString(n)
+	defer C.free(unsafe.Pointer(cn))
+	return (*BitStreamFilter)(C.av_bsf_get_by_name(cn))
 }
-*/
 
-//BitStreamFilter *av_bitstream_filter_next (const BitStreamFilter *f)
-/*func (f *BitStreamFilter) AvBitstreamFilterNext() *BitStreamFilter {
-	return (*BitStreamFilter)(C.av_bitstream_filter_next((*C.struct_AVBitStreamFilter)(f)))
+//Allocate a context for the given bitstream filter.
+func AvBsfAlloc(f *BitStreamFilter, ctx **BSFContext) int {
+	return int(C.av_bsf_alloc((*C.struct_AVBitStreamFilter)(f), (**C.struct_AVBSFContext)(unsafe.Pointer(ctx))))
 }
-*/
 
-//Filter bitstream.
-/*func (bfx *BitStreamFilterContext) AvBitstreamFilterFilter(ctxt *Context, a string, p **uint8, ps *int, b *uint8, bs, k int) int {
-	return int(C.av_bitstream_filter_filter((*C.struct_AVBitStreamFilterContext)(bfx), (*C.struct_AVCodecContext)(ctxt), C.CString(a), (**C.uint8_t)(unsafe.Pointer(p)), (*C.int)(unsafe.Pointer(ps)), (*C.uint8_t)(b), C.int(bs), C.int(k)))
+//Prepare the filter for use, after all the parameters and options have been set.
+func (ctx *BSFContext) AvBsfInit() int {
+	return int(C.av_bsf_init((*C.struct_AVBSFContext)(ctx)))
 }
-*/
 
-//Release bitstream filter context.
-/*func (bfx *BitStreamFilterContext) AvBitstreamFilterClose() {
-	C.av_bitstream_filter_close((*C.struct_AVBitStreamFilterContext)(bfx))
+//Submit a packet for filtering, the filter takes the ownership of its reference and resets it.
+func (ctx *BSFContext) AvBsfSendPacket(p *Packet) int {
+	return int(C.av_bsf_send_packet((*C.struct_AVBSFContext)(ctx), (*C.struct_AVPacket)(p)))
+}
+
+//Retrieve a filtered packet.
+func (ctx *BSFContext) AvBsfReceivePacket(p *Packet) int {
+	return int(C.av_bsf_receive_packet((*C.struct_AVBSFContext)(ctx), (*C.struct_AVPacket)(p)))
+}
+
+//Reset the internal state of the filter, discarding the packets it holds.
+func (ctx *BSFContext) AvBsfFlush() {
+	C.av_bsf_flush((*C.struct_AVBSFContext)(ctx))
+}
+
+//Free a bitstream filter context and everything associated with it.
+func AvBsfFree(ctx **BSFContext) {
+	C.av_bsf_free((**C.struct_AVBSFContext)(unsafe.Pointer(ctx)))
+}
+
+//Parse a chain of bitstream filters such as "h264_metadata=level=4.1,dump_extra" into a single context.
+func AvBsfListParseStr(s string, ctx **BSFContext) int {
+	cs := C.CString(s)
+	defer C.free(unsafe.Pointer(cs))
+	return int(C.av_bsf_list_parse_str(cs, (**C.struct_AVBSFContext)(unsafe.Pointer(ctx))))
+}
+
+//Return the name of the bitstream filter.
+func (f *BitStreamFilter) Name() string {
+	return C.GoString(f.name)
+}
+
+//BitstreamFilter runs packets through a bitstream filter or a chain of them,
+//e.g. "h264_mp4toannexb" to remux MP4 to MPEG-TS, or "aac_adtstoasc" for the reverse.
+//The codec parameters and the time base of the packets are given at creation,
+//and those of the filtered packets are available to set up the output stream.
+type BitstreamFilter struct {
+	ctx *C.struct_AVBSFContext
+}
+
+//Create a BitstreamFilter from a description of filters with their options separated by commas,
+//such as "h264_metadata=level=4.1,dump_extra", for packets of the given codec parameters and time base.
+//An empty description passes the packets through.
+func NewBitstreamFilter(desc string, par *CodecParameters, timeBase avutil.Rational) (*BitstreamFilter, error) {
+	if desc == "" {
+		desc = "null"
+	}
+	var ctx *BSFContext
+	if ret := AvBsfListParseStr(desc, &ctx); ret < 0 {
+		return nil, fmt.Errorf("Bitstream filter %q: %w", desc, &avutil.Error{Num: ret})
+	}
+	f := &BitstreamFilter{ctx: (*C.struct_AVBSFContext)(ctx)}
+	if par != nil {
+		if ret := C.avcodec_parameters_copy(f.ctx.par_in, (*C.struct_AVCodecParameters)(par)); ret < 0 {
+			f.Free()
+			return nil, &avutil.Error{Num: int(ret)}
+		}
+	}
+	f.ctx.time_base_in = *(*C.AVRational)(unsafe.Pointer(&timeBase))
+	if ret := C.av_bsf_init(f.ctx); ret < 0 {
+		f.Free()
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	return f, nil
+}
+
+//Return the codec parameters of the filtered packets, owned by the filter, e.g. to copy into the output stream.
+func (f *BitstreamFilter) OutputParameters() *CodecParameters {
+	return (*CodecParameters)(f.ctx.par_out)
+}
+
+//Copy the codec parameters of the filtered packets into par.
+func (f *BitstreamFilter) CopyOutputParameters(par *CodecParameters) error {
+	if ret := C.avcodec_parameters_copy((*C.struct_AVCodecParameters)(par), f.ctx.par_out); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
+
+//Return the time base of the timestamps of the filtered packets.
+func (f *BitstreamFilter) OutputTimeBase() avutil.Rational {
+	return *(*avutil.Rational)(unsafe.Pointer(&f.ctx.time_base_out))
+}
+
+//Send a packet to the filter, which takes the ownership of its reference and resets it, nil signaling the end of the stream.
+//AVERROR_EAGAIN is returned when filtered packets must be received first.
+func (f *BitstreamFilter) Send(p *Packet) error {
+	if ret := C.av_bsf_send_packet(f.ctx, (*C.struct_AVPacket)(p)); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
+
+//Receive a filtered packet into p, AVERROR_EAGAIN being returned when more packets must be sent,
+//and AVERROR_EOF once the end of the stream has been sent and all the packets received.
+func (f *BitstreamFilter) Receive(p *Packet) error {
+	if ret := C.av_bsf_receive_packet(f.ctx, (*C.struct_AVPacket)(p)); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
+
+//Filter a packet and return the packets it results in, which may be none, owned by the caller and freed with AvPacketFree().
+//The filter takes the ownership of the reference of p and resets it.
+func (f *BitstreamFilter) Filter(p *Packet) ([]*Packet, error) {
+	if err := f.Send(p); err != nil {
+		return nil, err
+	}
+	return f.drain()
+}
+
+//Signal the end of the stream and return the packets the filter still held.
+func (f *BitstreamFilter) Flush() ([]*Packet, error) {
+	if err := f.Send(nil); err != nil {
+		return nil, err
+	}
+	return f.drain()
+}
+
+func (f *BitstreamFilter) drain() ([]*Packet, error) {
+	var packets []*Packet
+	for {
+		p := AvPacketAlloc()
+		if p == nil {
+			freePackets(packets)
+			return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+		}
+		ret := C.av_bsf_receive_packet(f.ctx, (*C.struct_AVPacket)(p))
+		if ret < 0 {
+			AvPacketFree(p)
+			if ret == avutil.AVERROR_EAGAIN || ret == avutil.AVERROR_EOF {
+				return packets, nil
+			}
+			freePackets(packets)
+			return nil, &avutil.Error{Num: int(ret)}
+		}
+		packets = append(packets, p)
+	}
+}
+
+//Discard the packets held by the filter and reset its state, e.g. after seeking, or to start a new stream after Flush().
+func (f *BitstreamFilter) Reset() {
+	C.av_bsf_flush(f.ctx)
+}
+
+//Return the underlying bitstream filter context.
+func (f *BitstreamFilter) Context() *BSFContext {
+	return (*BSFContext)(f.ctx)
 }
-*/
 
-//Create and initialize a bitstream filter context given a bitstream filter name.
-/*func AvBitstreamFilterInit(n string) *BitStreamFilterContext {
-	return (*BitStreamFilterContext)(C.av_bitstream_filter_init(C.CString(n)))
+func (f *BitstreamFilter) Free() {
+	C.av_bsf_free(&f.ctx)
 }
-*/
\ No newline at end of file
@@ -0,0 +1,150 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+/*
+#cgo pkg-config: libavcodec libavutil
+#include <libavcodec/avcodec.h>
+#include <libavutil/channel_layout.h>
+#include <libavutil/mem.h>
+
+#if LIBAVUTIL_VERSION_INT >= AV_VERSION_INT(57, 28, 100)
+typedef struct {
+	AVChannelLayout* l;
+} goav_ch_layout_ref;
+
+#define GOAV_CH_LAYOUT_REF(obj) ((goav_ch_layout_ref){ &(obj)->ch_layout })
+
+static inline int goav_ch_layout_get(goav_ch_layout_ref r, int* nb, uint64_t* mask)
+{
+	*nb = r.l->nb_channels;
+	*mask = r.l->order == AV_CHANNEL_ORDER_CUSTOM ? 0 : r.l->u.mask;
+	return r.l->order;
+}
+
+static inline int goav_ch_layout_channel(goav_ch_layout_ref r, unsigned int idx)
+{
+	return av_channel_layout_channel_from_index(r.l, idx);
+}
+
+static inline int goav_ch_layout_set(goav_ch_layout_ref r, int order, int nb, uint64_t mask, const int* map)
+{
+	AVChannelLayout l = { 0 };
+	int i;
+	l.order = order;
+	l.nb_channels = nb;
+	if (order == AV_CHANNEL_ORDER_CUSTOM) {
+		l.u.map = av_calloc(nb, sizeof(*l.u.map));
+		if (!l.u.map)
+			return AVERROR(ENOMEM);
+		for (i = 0; i < nb; i++)
+			l.u.map[i].id = map[i];
+	} else {
+		l.u.mask = mask;
+	}
+	av_channel_layout_uninit(r.l);
+	*r.l = l;
+	return 0;
+}
+#else
+typedef struct {
+	uint64_t* mask;
+	int* channels;
+} goav_ch_layout_ref;
+
+#define GOAV_CH_LAYOUT_REF(obj) ((goav_ch_layout_ref){ &(obj)->channel_layout, &(obj)->channels })
+
+static inline int goav_ch_layout_get(goav_ch_layout_ref r, int* nb, uint64_t* mask)
+{
+	*nb = *r.channels;
+	*mask = *r.mask;
+	return *r.mask ? 1 : 0;
+}
+
+static inline int goav_ch_layout_channel(goav_ch_layout_ref r, unsigned int idx)
+{
+	if (!*r.mask)
+		return 0x300;
+	return av_log2(av_channel_layout_extract_channel(*r.mask, idx));
+}
+
+static inline int goav_ch_layout_set(goav_ch_layout_ref r, int order, int nb, uint64_t mask, const int* map)
+{
+	*r.mask = order == 1 || order == 2 ? mask : 0;
+	*r.channels = nb;
+	return 0;
+}
+#endif
+
+static inline goav_ch_layout_ref goav_ctx_ch_layout(AVCodecContext* c)
+{
+	return GOAV_CH_LAYOUT_REF(c);
+}
+
+static inline goav_ch_layout_ref goav_par_ch_layout(AVCodecParameters* p)
+{
+	return GOAV_CH_LAYOUT_REF(p);
+}
+*/
+import "C"
+import (
+	"github.com/alon-ne/goav/avutil"
+)
+
+//Return the audio channel layout, read from ch_layout or from the legacy channel_layout/channels pair depending on the libavcodec version.
+func (ctxt *Context) ChLayout() avutil.ChannelLayout {
+	return getChLayout(C.goav_ctx_ch_layout((*C.struct_AVCodecContext)(ctxt)))
+}
+
+//Set the audio channel layout, see ChLayout().
+func (ctxt *Context) SetChLayout(l avutil.ChannelLayout) error {
+	return setChLayout(C.goav_ctx_ch_layout((*C.struct_AVCodecContext)(ctxt)), l)
+}
+
+//Return the audio channel layout, read from ch_layout or from the legacy channel_layout/channels pair depending on the libavcodec version.
+func (p *CodecParameters) ChLayout() avutil.ChannelLayout {
+	return getChLayout(C.goav_par_ch_layout((*C.struct_AVCodecParameters)(p)))
+}
+
+//Set the audio channel layout, see ChLayout().
+func (p *CodecParameters) SetChLayout(l avutil.ChannelLayout) error {
+	return setChLayout(C.goav_par_ch_layout((*C.struct_AVCodecParameters)(p)), l)
+}
+
+func getChLayout(r C.goav_ch_layout_ref) avutil.ChannelLayout {
+	var nb C.int
+	var mask C.uint64_t
+	l := avutil.ChannelLayout{Order: avutil.ChannelOrder(C.goav_ch_layout_get(r, &nb, &mask))}
+	l.NbChannels = int(nb)
+	l.Mask = uint64(mask)
+	if l.Order == avutil.AV_CHANNEL_ORDER_CUSTOM {
+		l.Map = make([]avutil.Channel, l.NbChannels)
+		for i := range l.Map {
+			l.Map[i] = avutil.Channel(C.goav_ch_layout_channel(r, C.uint(i)))
+		}
+	}
+	return l
+}
+
+func setChLayout(r C.goav_ch_layout_ref, l avutil.ChannelLayout) error {
+	mask := l.Mask
+	var cmap *C.int
+	if l.Order == avutil.AV_CHANNEL_ORDER_CUSTOM {
+		if len(l.Map) != l.NbChannels {
+			return &avutil.Error{Num: avutil.AVERROR_EINVAL}
+		}
+		mask = l.LegacyMask()
+		m := make([]C.int, len(l.Map))
+		for i, ch := range l.Map {
+			m[i] = C.int(ch)
+		}
+		if len(m) > 0 {
+			cmap = &m[0]
+		}
+	}
+	if ret := C.goav_ch_layout_set(r, C.int(l.Order), C.int(l.NbChannels), C.uint64_t(mask), cmap); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+//#cgo pkg-config: libavcodec
+//#include <libavcodec/avcodec.h>
+//#include <stdlib.h>
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+//Return the name of the codec, e.g. "h264" or "aac".
+func (c CodecId) String() string {
+	return AvcodecGetName(c)
+}
+
+//Return the codec id matching the given codec descriptor name, the inverse of CodecId.String().
+func ParseCodecId(name string) (CodecId, error) {
+	if name == "none" {
+		return CodecId(AV_CODEC_ID_NONE), nil
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	d := C.avcodec_descriptor_get_by_name(cname)
+	if d == nil {
+		return CodecId(AV_CODEC_ID_NONE), fmt.Errorf("Unknown codec %q", name)
+	}
+	return CodecId(d.id), nil
+}
+
+func (c CodecId) MarshalText() ([]byte, error) {
+	if c != CodecId(AV_CODEC_ID_NONE) && C.avcodec_descriptor_get((C.enum_AVCodecID)(c)) == nil {
+		return nil, fmt.Errorf("Invalid codec id %d", int(c))
+	}
+	return []byte(c.String()), nil
+}
+
+func (c *CodecId) UnmarshalText(text []byte) error {
+	parsed, err := ParseCodecId(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
@@ -8,6 +8,14 @@ func (p* CodecParameters) CodecType() MediaType {
 	return MediaType(p.codec_type)
 }
 
+func (p *CodecParameters) Width() int {
+	return int(p.width)
+}
+
+func (p *CodecParameters) Height() int {
+	return int(p.height)
+}
+
 func (p *CodecParameters) AvcodecParametersToContext() {
 
 }
\ No newline at end of file
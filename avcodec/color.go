@@ -0,0 +1,106 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+//#cgo pkg-config: libavutil
+//#include <libavutil/pixdesc.h>
+import "C"
+import (
+	"fmt"
+)
+
+const (
+	AVCOL_PRI_BT709       AvColorPrimaries = 1
+	AVCOL_PRI_UNSPECIFIED AvColorPrimaries = 2
+	AVCOL_PRI_BT470M      AvColorPrimaries = 4
+	AVCOL_PRI_BT470BG     AvColorPrimaries = 5
+	AVCOL_PRI_SMPTE170M   AvColorPrimaries = 6
+	AVCOL_PRI_SMPTE240M   AvColorPrimaries = 7
+	AVCOL_PRI_FILM        AvColorPrimaries = 8
+	AVCOL_PRI_BT2020      AvColorPrimaries = 9
+	AVCOL_PRI_SMPTE428    AvColorPrimaries = 10
+	AVCOL_PRI_SMPTE431    AvColorPrimaries = 11
+	AVCOL_PRI_SMPTE432    AvColorPrimaries = 12
+	AVCOL_PRI_EBU3213     AvColorPrimaries = 22
+)
+
+const (
+	AVCOL_TRC_BT709        AvColorTransferCharacteristic = 1
+	AVCOL_TRC_UNSPECIFIED  AvColorTransferCharacteristic = 2
+	AVCOL_TRC_GAMMA22      AvColorTransferCharacteristic = 4
+	AVCOL_TRC_GAMMA28      AvColorTransferCharacteristic = 5
+	AVCOL_TRC_SMPTE170M    AvColorTransferCharacteristic = 6
+	AVCOL_TRC_SMPTE240M    AvColorTransferCharacteristic = 7
+	AVCOL_TRC_LINEAR       AvColorTransferCharacteristic = 8
+	AVCOL_TRC_LOG          AvColorTransferCharacteristic = 9
+	AVCOL_TRC_LOG_SQRT     AvColorTransferCharacteristic = 10
+	AVCOL_TRC_IEC61966_2_4 AvColorTransferCharacteristic = 11
+	AVCOL_TRC_BT1361_ECG   AvColorTransferCharacteristic = 12
+	AVCOL_TRC_IEC61966_2_1 AvColorTransferCharacteristic = 13
+	AVCOL_TRC_BT2020_10    AvColorTransferCharacteristic = 14
+	AVCOL_TRC_BT2020_12    AvColorTransferCharacteristic = 15
+	AVCOL_TRC_SMPTE2084    AvColorTransferCharacteristic = 16
+	AVCOL_TRC_SMPTE428     AvColorTransferCharacteristic = 17
+	AVCOL_TRC_ARIB_STD_B67 AvColorTransferCharacteristic = 18
+)
+
+const (
+	AVCOL_SPC_RGB                AvColorSpace = 0
+	AVCOL_SPC_BT709              AvColorSpace = 1
+	AVCOL_SPC_UNSPECIFIED        AvColorSpace = 2
+	AVCOL_SPC_FCC                AvColorSpace = 4
+	AVCOL_SPC_BT470BG            AvColorSpace = 5
+	AVCOL_SPC_SMPTE170M          AvColorSpace = 6
+	AVCOL_SPC_SMPTE240M          AvColorSpace = 7
+	AVCOL_SPC_YCGCO              AvColorSpace = 8
+	AVCOL_SPC_BT2020_NCL         AvColorSpace = 9
+	AVCOL_SPC_BT2020_CL          AvColorSpace = 10
+	AVCOL_SPC_SMPTE2085          AvColorSpace = 11
+	AVCOL_SPC_CHROMA_DERIVED_NCL AvColorSpace = 12
+	AVCOL_SPC_CHROMA_DERIVED_CL  AvColorSpace = 13
+	AVCOL_SPC_ICTCP              AvColorSpace = 14
+)
+
+const (
+	AVCOL_RANGE_UNSPECIFIED AvColorRange = 0
+	AVCOL_RANGE_MPEG        AvColorRange = 1
+	AVCOL_RANGE_JPEG        AvColorRange = 2
+)
+
+//Return the name of the color primaries, e.g. "bt709".
+func (p AvColorPrimaries) String() string {
+	if s := C.av_color_primaries_name((C.enum_AVColorPrimaries)(p)); s != nil {
+		return C.GoString(s)
+	}
+	return fmt.Sprintf("AvColorPrimaries(%d)", int(p))
+}
+
+//Return the name of the transfer characteristic, e.g. "smpte2084".
+func (t AvColorTransferCharacteristic) String() string {
+	if s := C.av_color_transfer_name((C.enum_AVColorTransferCharacteristic)(t)); s != nil {
+		return C.GoString(s)
+	}
+	return fmt.Sprintf("AvColorTransferCharacteristic(%d)", int(t))
+}
+
+//Report whether the transfer characteristic is one of the HDR ones, PQ (SMPTE ST 2084) or HLG (ARIB STD-B67).
+func (t AvColorTransferCharacteristic) IsHDR() bool {
+	return t == AVCOL_TRC_SMPTE2084 || t == AVCOL_TRC_ARIB_STD_B67
+}
+
+//Return the name of the colorspace, e.g. "bt709".
+func (s AvColorSpace) String() string {
+	if name := C.av_color_space_name((C.enum_AVColorSpace)(s)); name != nil {
+		return C.GoString(name)
+	}
+	return fmt.Sprintf("AvColorSpace(%d)", int(s))
+}
+
+//Return the name of the color range, "tv" for limited range and "pc" for full range.
+func (r AvColorRange) String() string {
+	if s := C.av_color_range_name((C.enum_AVColorRange)(r)); s != nil {
+		return C.GoString(s)
+	}
+	return fmt.Sprintf("AvColorRange(%d)", int(r))
+}
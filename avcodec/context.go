@@ -143,7 +143,8 @@ func (ctxt *Context) AvcodecIsOpen() int {
 	return int(C.avcodec_is_open((*C.struct_AVCodecContext)(ctxt)))
 }
 
-//Parse a packet.
+//Parse a packet, p and ps receiving the frame it completes, if any, which points into the parser or into b.
+//See StreamParser for a safe use from Go.
 func (ctxt *Context) AvParserParse2(ctxtp *ParserContext, p **uint8, ps *int, b *uint8, bs int, pt, dt, po int64) int {
 	return int(C.av_parser_parse2((*C.struct_AVCodecParserContext)(ctxtp), (*C.struct_AVCodecContext)(ctxt), (**C.uint8_t)(unsafe.Pointer(p)), (*C.int)(unsafe.Pointer(ps)), (*C.uint8_t)(b), C.int(bs), (C.int64_t)(pt), (C.int64_t)(dt), (C.int64_t)(po)))
 }
@@ -168,7 +169,8 @@ func (ctxt *Context) AvCodecReceivePacket(packet *Packet) int {
 	return int(C.avcodec_receive_packet((*C.struct_AVCodecContext)(ctxt), (*C.struct_AVPacket)(packet)))
 }
 
-func AvParserInit(c int) *ParserContext {
+//Allocate a parser for the given codec, nil being returned when there is none.
+func AvParserInit(c CodecId) *ParserContext {
 	return (*ParserContext)(C.av_parser_init(C.int(c)))
 }
 
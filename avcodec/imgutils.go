@@ -0,0 +1,105 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+/*
+#cgo pkg-config: libavutil
+#include <libavutil/imgutils.h>
+#include <libavutil/frame.h>
+
+static inline int goav_image_copy_from_buffer(AVFrame* frame, const uint8_t* src, int srcSize, int align)
+{
+	uint8_t* data[4];
+	int linesize[4];
+	int size = av_image_fill_arrays(data, linesize, src, frame->format, frame->width, frame->height, align);
+	if (size < 0)
+		return size;
+	if (size > srcSize)
+		return AVERROR(EINVAL);
+	av_image_copy(frame->data, frame->linesize, (const uint8_t**)data, linesize, frame->format, frame->width, frame->height);
+	return size;
+}
+*/
+import "C"
+import (
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+//Return the size in bytes of the amount of data required to store an image with the given parameters.
+func AvImageGetBufferSize(pf PixelFormat, w, h, a int) int {
+	return int(C.av_image_get_buffer_size((C.enum_AVPixelFormat)(pf), C.int(w), C.int(h), C.int(a)))
+}
+
+//Setup the data pointers and linesizes based on the specified image parameters and the provided array.
+func AvImageFillArrays(d *[4]*uint8, l *[4]int32, src *uint8, pf PixelFormat, w, h, a int) int {
+	return int(C.av_image_fill_arrays((**C.uint8_t)(unsafe.Pointer(d)), (*C.int)(unsafe.Pointer(l)), (*C.uint8_t)(src), (C.enum_AVPixelFormat)(pf), C.int(w), C.int(h), C.int(a)))
+}
+
+//Copy image data from an image into a buffer.
+func AvImageCopyToBuffer(dst *uint8, ds int, src *[4]*uint8, l *[4]int32, pf PixelFormat, w, h, a int) int {
+	return int(C.av_image_copy_to_buffer((*C.uint8_t)(dst), C.int(ds), (**C.uint8_t)(unsafe.Pointer(src)), (*C.int)(unsafe.Pointer(l)), (C.enum_AVPixelFormat)(pf), C.int(w), C.int(h), C.int(a)))
+}
+
+//Allocate an image with size w and h and pixel format pix_fmt, and fill pointers and linesizes accordingly.
+//The allocated image buffer has to be freed by using AvFreep(&d[0]).
+func AvImageAlloc(d *[4]*uint8, l *[4]int32, w, h int, pf PixelFormat, a int) int {
+	return int(C.av_image_alloc((**C.uint8_t)(unsafe.Pointer(d)), (*C.int)(unsafe.Pointer(l)), C.int(w), C.int(h), (C.enum_AVPixelFormat)(pf), C.int(a)))
+}
+
+//Copy image in src_data to dst_data.
+func AvImageCopy(d *[4]*uint8, dl *[4]int32, s *[4]*uint8, sl *[4]int32, pf PixelFormat, w, h int) {
+	C.av_image_copy((**C.uint8_t)(unsafe.Pointer(d)), (*C.int)(unsafe.Pointer(dl)), (**C.uint8_t)(unsafe.Pointer(s)), (*C.int)(unsafe.Pointer(sl)), (C.enum_AVPixelFormat)(pf), C.int(w), C.int(h))
+}
+
+//Pack the planes of a video frame into one contiguous buffer, laid out as av_image_copy_to_buffer() does.
+func PackImage(f *avutil.Frame, a int) ([]byte, error) {
+	cf := (*C.struct_AVFrame)(unsafe.Pointer(f))
+	pf := PixelFormat(cf.format)
+	size := AvImageGetBufferSize(pf, int(cf.width), int(cf.height), a)
+	if size < 0 {
+		return nil, &avutil.Error{Num: size}
+	}
+	buf := make([]byte, size)
+	if size == 0 {
+		return buf, nil
+	}
+	ret := C.av_image_copy_to_buffer((*C.uint8_t)(unsafe.Pointer(&buf[0])), C.int(size), &cf.data[0], &cf.linesize[0], (C.enum_AVPixelFormat)(cf.format), cf.width, cf.height, C.int(a))
+	if ret < 0 {
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	return buf[:ret], nil
+}
+
+//Unpack a buffer produced by PackImage() into a video frame.
+//The frame format, width and height must be set, and its buffers are allocated if needed.
+func UnpackImage(f *avutil.Frame, buf []byte, a int) error {
+	if len(buf) == 0 {
+		return &avutil.Error{Num: avutil.AVERROR_EINVAL}
+	}
+	cf := (*C.struct_AVFrame)(unsafe.Pointer(f))
+	if err := makeFrameWritable(f); err != nil {
+		return err
+	}
+	if ret := C.goav_image_copy_from_buffer(cf, (*C.uint8_t)(unsafe.Pointer(&buf[0])), C.int(len(buf)), C.int(a)); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
+
+//Allocate the frame buffers if the frame has none yet, otherwise make sure they are writable.
+func makeFrameWritable(f *avutil.Frame) error {
+	cf := (*C.struct_AVFrame)(unsafe.Pointer(f))
+	var ret int
+	if cf.data[0] == nil {
+		ret = avutil.AvFrameGetBuffer(f, 0)
+	} else {
+		ret = avutil.AvFrameMakeWritable(f)
+	}
+	if ret < 0 {
+		return &avutil.Error{Num: ret}
+	}
+	return nil
+}
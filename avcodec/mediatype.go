@@ -0,0 +1,32 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+import (
+	"github.com/alon-ne/goav/avutil"
+)
+
+//Return the name of the media type, e.g. "video" or "audio".
+func (m MediaType) String() string {
+	return avutil.MediaType(m).String()
+}
+
+//Return the media type matching the given name, the inverse of MediaType.String().
+func ParseMediaType(name string) (MediaType, error) {
+	m, err := avutil.ParseMediaType(name)
+	return MediaType(m), err
+}
+
+func (m MediaType) MarshalText() ([]byte, error) {
+	return avutil.MediaType(m).MarshalText()
+}
+
+func (m *MediaType) UnmarshalText(text []byte) error {
+	parsed, err := ParseMediaType(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -11,6 +11,17 @@ import (
 	"github.com/alon-ne/goav/avutil"
 )
 
+//Allocate a packet and set its fields to default values.
+func AvPacketAlloc() *Packet {
+	return (*Packet)(C.av_packet_alloc())
+}
+
+//Free the packet, unreferencing its buffer.
+func AvPacketFree(p *Packet) {
+	cp := (*C.struct_AVPacket)(p)
+	C.av_packet_free(&cp)
+}
+
 //Initialize optional fields of a packet with default values.
 func (p *Packet) AvInitPacket() {
 	C.av_init_packet((*C.struct_AVPacket)(p))
@@ -0,0 +1,192 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+/*
+#cgo pkg-config: libavcodec
+#include <libavcodec/avcodec.h>
+#include <string.h>
+*/
+import "C"
+import (
+	"fmt"
+	"io"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+//ParsedPacket is a complete frame split from a raw elementary stream by a StreamParser,
+//an access unit for video, along with what the parser found out about it.
+type ParsedPacket struct {
+	//Data of the frame, owned by the caller.
+	Data []byte
+	//Timestamps given to Parse() with the data the frame starts in, AV_NOPTS_VALUE when unknown.
+	Pts, Dts int64
+	//Byte offset in the stream of the chunk given to Parse() the frame starts in.
+	Pos int64
+	//Whether the frame can be decoded on its own, always true for audio.
+	KeyFrame bool
+	//Picture type of a video frame, AV_PICTURE_TYPE_NONE when unknown.
+	PictType avutil.AvPictureType
+	//Dimensions of a video frame, zero when unknown.
+	Width, Height int
+	//Duration of the frame, in samples at SampleRate for audio, zero when unknown.
+	Duration int
+	//Sample rate and channel layout of an audio frame, zero when unknown.
+	SampleRate    int
+	ChannelLayout avutil.ChannelLayout
+}
+
+//StreamParser splits a raw elementary stream, such as H.264/HEVC Annex B, AAC ADTS or MP3, into frames
+//fit to be put in packets, however the stream is cut into the chunks it is given.
+type StreamParser struct {
+	pc    *C.struct_AVCodecParserContext
+	ctx   *C.struct_AVCodecContext
+	audio bool
+	pos   int64
+}
+
+//Create a StreamParser for streams of the given codec.
+func NewStreamParser(id CodecId) (*StreamParser, error) {
+	pc := C.av_parser_init(C.int(id))
+	if pc == nil {
+		return nil, fmt.Errorf("No parser for codec %q", id)
+	}
+	ctx := C.avcodec_alloc_context3(nil)
+	if ctx == nil {
+		C.av_parser_close(pc)
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	ctx.codec_id = (C.enum_AVCodecID)(id)
+	ctx.codec_type = C.avcodec_get_type((C.enum_AVCodecID)(id))
+	return &StreamParser{pc: pc, ctx: ctx, audio: ctx.codec_type == C.AVMEDIA_TYPE_AUDIO}, nil
+}
+
+//Parse the next chunk of the stream and return the frames it completes, which may be none,
+//and the number of bytes consumed, always all of them unless an error is returned.
+//pts and dts are the timestamps of the chunk, AV_NOPTS_VALUE when unknown,
+//given to the frame starting in it. Empty data signals the end of the stream and returns the last frames, see Flush().
+func (p *StreamParser) Parse(data []byte, pts, dts int64) (packets []ParsedPacket, consumed int, err error) {
+	//The parser may read past the end of the data, and the frames it returns may point into it.
+	var buf *C.uint8_t
+	if len(data) > 0 {
+		buf = (*C.uint8_t)(C.av_malloc(C.size_t(len(data) + C.AV_INPUT_BUFFER_PADDING_SIZE)))
+		if buf == nil {
+			return nil, 0, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+		}
+		defer C.av_free(unsafe.Pointer(buf))
+		C.memcpy(unsafe.Pointer(buf), unsafe.Pointer(&data[0]), C.size_t(len(data)))
+		C.memset(unsafe.Pointer(uintptr(unsafe.Pointer(buf))+uintptr(len(data))), 0, C.AV_INPUT_BUFFER_PADDING_SIZE)
+	}
+	flush := len(data) == 0
+	for {
+		var out *C.uint8_t
+		var outSize C.int
+		in := (*C.uint8_t)(unsafe.Pointer(uintptr(unsafe.Pointer(buf)) + uintptr(consumed)))
+		n := C.av_parser_parse2(p.pc, p.ctx, &out, &outSize, in, C.int(len(data)-consumed),
+			C.int64_t(pts), C.int64_t(dts), C.int64_t(p.pos))
+		consumed += int(n)
+		p.pos += int64(n)
+		//Only the first frame starting in the chunk gets its timestamps.
+		pts, dts = avutil.AV_NOPTS_VALUE, avutil.AV_NOPTS_VALUE
+		if outSize > 0 {
+			packets = append(packets, p.packet(out, outSize))
+		}
+		if consumed >= len(data) && !(flush && outSize > 0) {
+			return packets, consumed, nil
+		}
+	}
+}
+
+//Signal the end of the stream and return the frames the parser still held.
+//The parser can then be used for a new stream.
+func (p *StreamParser) Flush() []ParsedPacket {
+	//Nothing is allocated without data, so that no error can occur.
+	packets, _, _ := p.Parse(nil, avutil.AV_NOPTS_VALUE, avutil.AV_NOPTS_VALUE)
+	return packets
+}
+
+func (p *StreamParser) packet(out *C.uint8_t, size C.int) ParsedPacket {
+	pkt := ParsedPacket{
+		Data:     C.GoBytes(unsafe.Pointer(out), size),
+		Pts:      int64(p.pc.pts),
+		Dts:      int64(p.pc.dts),
+		Pos:      int64(p.pc.pos),
+		KeyFrame: p.audio || p.pc.key_frame == 1,
+		PictType: avutil.AvPictureType(p.pc.pict_type),
+		Width:    int(p.pc.width),
+		Height:   int(p.pc.height),
+		Duration: int(p.pc.duration),
+	}
+	if p.audio {
+		pkt.SampleRate = int(p.ctx.sample_rate)
+		pkt.ChannelLayout = (*Context)(unsafe.Pointer(p.ctx)).ChLayout()
+	}
+	return pkt
+}
+
+//Return the codec context the parser fills in with the stream properties, e.g. the profile and level.
+func (p *StreamParser) Context() *Context {
+	return (*Context)(unsafe.Pointer(p.ctx))
+}
+
+func (p *StreamParser) Free() {
+	C.av_parser_close(p.pc)
+	C.avcodec_free_context(&p.ctx)
+}
+
+//Splitter reads a raw elementary stream and splits it into frames with a StreamParser,
+//e.g. to packetize a stream received from a socket.
+type Splitter struct {
+	parser *StreamParser
+	r      io.Reader
+	buf    []byte
+	queue  []ParsedPacket
+	err    error
+}
+
+//Create a Splitter reading a stream of the given codec from r.
+func NewSplitter(r io.Reader, id CodecId) (*Splitter, error) {
+	p, err := NewStreamParser(id)
+	if err != nil {
+		return nil, err
+	}
+	return &Splitter{parser: p, r: r, buf: make([]byte, 64*1024)}, nil
+}
+
+//Return the next frame of the stream, io.EOF once the stream has ended and all the frames have been returned.
+//An error reading the stream is returned after the frames completed before it.
+func (s *Splitter) Next() (ParsedPacket, error) {
+	for len(s.queue) == 0 {
+		if s.err != nil {
+			return ParsedPacket{}, s.err
+		}
+		n, err := s.r.Read(s.buf)
+		if n > 0 {
+			var perr error
+			s.queue, _, perr = s.parser.Parse(s.buf[:n], avutil.AV_NOPTS_VALUE, avutil.AV_NOPTS_VALUE)
+			if perr != nil {
+				s.err = perr
+				continue
+			}
+		}
+		if err == io.EOF {
+			s.queue = append(s.queue, s.parser.Flush()...)
+		}
+		s.err = err
+	}
+	pkt := s.queue[0]
+	s.queue = s.queue[1:]
+	return pkt, nil
+}
+
+//Return the parser splitting the stream.
+func (s *Splitter) Parser() *StreamParser {
+	return s.parser
+}
+
+func (s *Splitter) Free() {
+	s.parser.Free()
+}
@@ -0,0 +1,187 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+//#cgo pkg-config: libavutil
+//#include <libavutil/pixdesc.h>
+//#ifndef AV_PIX_FMT_FLAG_FLOAT
+//#define AV_PIX_FMT_FLAG_FLOAT (1 << 9)
+//#endif
+import "C"
+
+type AvPixFmtDescriptor C.struct_AVPixFmtDescriptor
+
+const (
+	AV_PIX_FMT_FLAG_BE        = uint64(C.AV_PIX_FMT_FLAG_BE)
+	AV_PIX_FMT_FLAG_PAL       = uint64(C.AV_PIX_FMT_FLAG_PAL)
+	AV_PIX_FMT_FLAG_BITSTREAM = uint64(C.AV_PIX_FMT_FLAG_BITSTREAM)
+	AV_PIX_FMT_FLAG_HWACCEL   = uint64(C.AV_PIX_FMT_FLAG_HWACCEL)
+	AV_PIX_FMT_FLAG_PLANAR    = uint64(C.AV_PIX_FMT_FLAG_PLANAR)
+	AV_PIX_FMT_FLAG_RGB       = uint64(C.AV_PIX_FMT_FLAG_RGB)
+	AV_PIX_FMT_FLAG_ALPHA     = uint64(C.AV_PIX_FMT_FLAG_ALPHA)
+	AV_PIX_FMT_FLAG_BAYER     = uint64(C.AV_PIX_FMT_FLAG_BAYER)
+	AV_PIX_FMT_FLAG_FLOAT     = uint64(C.AV_PIX_FMT_FLAG_FLOAT)
+)
+
+//PixFmtComponent describes where one component (e.g. Y, U, V, R, G, B or A) of a pixel is stored.
+type PixFmtComponent struct {
+	Plane  int
+	Step   int
+	Offset int
+	Shift  int
+	Depth  int
+}
+
+//PixFmtDescriptor is a Go copy of AVPixFmtDescriptor, describing how the pixels of a format are laid out in memory.
+type PixFmtDescriptor struct {
+	Format       PixelFormat
+	Name         string
+	Alias        string
+	Log2ChromaW  int
+	Log2ChromaH  int
+	Flags        uint64
+	Components   []PixFmtComponent
+	BitsPerPixel int
+	Planes       int
+}
+
+//Return a pixel format descriptor for provided pixel format or NULL if this pixel format is unknown.
+func AvPixFmtDescGet(p PixelFormat) *AvPixFmtDescriptor {
+	return (*AvPixFmtDescriptor)(C.av_pix_fmt_desc_get((C.enum_AVPixelFormat)(p)))
+}
+
+//Iterate over all pixel format descriptors known to libavutil.
+func (d *AvPixFmtDescriptor) AvPixFmtDescNext() *AvPixFmtDescriptor {
+	return (*AvPixFmtDescriptor)(C.av_pix_fmt_desc_next((*C.struct_AVPixFmtDescriptor)(d)))
+}
+
+//Return an PixelFormat id described by desc, or AV_PIX_FMT_NONE if desc is not a valid pointer to a pixel format descriptor.
+func (d *AvPixFmtDescriptor) AvPixFmtDescGetId() PixelFormat {
+	return (PixelFormat)(C.av_pix_fmt_desc_get_id((*C.struct_AVPixFmtDescriptor)(d)))
+}
+
+//Return the number of bits per pixel used by the pixel format described by pixdesc.
+func (d *AvPixFmtDescriptor) AvGetBitsPerPixel() int {
+	return int(C.av_get_bits_per_pixel((*C.struct_AVPixFmtDescriptor)(d)))
+}
+
+//Return number of planes in pix_fmt, a negative AVERROR if pix_fmt is not a valid pixel format.
+func (p PixelFormat) AvPixFmtCountPlanes() int {
+	return int(C.av_pix_fmt_count_planes((C.enum_AVPixelFormat)(p)))
+}
+
+//Utility function to swap the endianness of a pixel format, returns AV_PIX_FMT_NONE if it has no counterpart.
+func (p PixelFormat) AvPixFmtSwapEndianness() PixelFormat {
+	return (PixelFormat)(C.av_pix_fmt_swap_endianness((C.enum_AVPixelFormat)(p)))
+}
+
+//Copy the descriptor into a PixFmtDescriptor.
+func (d *AvPixFmtDescriptor) PixFmtDescriptor() *PixFmtDescriptor {
+	desc := &PixFmtDescriptor{
+		Format:       d.AvPixFmtDescGetId(),
+		Name:         C.GoString(d.name),
+		Log2ChromaW:  int(d.log2_chroma_w),
+		Log2ChromaH:  int(d.log2_chroma_h),
+		Flags:        uint64(d.flags),
+		Components:   make([]PixFmtComponent, int(d.nb_components)),
+		BitsPerPixel: d.AvGetBitsPerPixel(),
+	}
+	if d.alias != nil {
+		desc.Alias = C.GoString(d.alias)
+	}
+	for i := range desc.Components {
+		c := d.comp[i]
+		desc.Components[i] = PixFmtComponent{
+			Plane:  int(c.plane),
+			Step:   int(c.step),
+			Offset: int(c.offset),
+			Shift:  int(c.shift),
+			Depth:  int(c.depth),
+		}
+	}
+	desc.Planes = desc.Format.AvPixFmtCountPlanes()
+	return desc
+}
+
+//Return the descriptor of the pixel format, or nil if the format is unknown.
+func (p PixelFormat) Descriptor() *PixFmtDescriptor {
+	d := AvPixFmtDescGet(p)
+	if d == nil {
+		return nil
+	}
+	return d.PixFmtDescriptor()
+}
+
+//Return the descriptors of all pixel formats known to libavutil.
+func PixFmtDescriptors() []*PixFmtDescriptor {
+	var descs []*PixFmtDescriptor
+	for d := (*AvPixFmtDescriptor)(nil).AvPixFmtDescNext(); d != nil; d = d.AvPixFmtDescNext() {
+		descs = append(descs, d.PixFmtDescriptor())
+	}
+	return descs
+}
+
+func (d *PixFmtDescriptor) IsBigEndian() bool {
+	return d.Flags&AV_PIX_FMT_FLAG_BE != 0
+}
+
+func (d *PixFmtDescriptor) IsPaletted() bool {
+	return d.Flags&AV_PIX_FMT_FLAG_PAL != 0
+}
+
+func (d *PixFmtDescriptor) IsHwAccel() bool {
+	return d.Flags&AV_PIX_FMT_FLAG_HWACCEL != 0
+}
+
+func (d *PixFmtDescriptor) IsPlanar() bool {
+	return d.Flags&AV_PIX_FMT_FLAG_PLANAR != 0
+}
+
+func (d *PixFmtDescriptor) IsRGB() bool {
+	return d.Flags&AV_PIX_FMT_FLAG_RGB != 0
+}
+
+//Report whether the format stores luma and chroma, the same test swscale uses.
+func (d *PixFmtDescriptor) IsYUV() bool {
+	return !d.IsRGB() && len(d.Components) >= 2
+}
+
+func (d *PixFmtDescriptor) HasAlpha() bool {
+	return d.Flags&AV_PIX_FMT_FLAG_ALPHA != 0
+}
+
+func (d *PixFmtDescriptor) IsBayer() bool {
+	return d.Flags&AV_PIX_FMT_FLAG_BAYER != 0
+}
+
+func (d *PixFmtDescriptor) IsFloat() bool {
+	return d.Flags&AV_PIX_FMT_FLAG_FLOAT != 0
+}
+
+//Return the largest component depth in bits.
+func (d *PixFmtDescriptor) Depth() int {
+	depth := 0
+	for _, c := range d.Components {
+		if c.Depth > depth {
+			depth = c.Depth
+		}
+	}
+	return depth
+}
+
+//Return the width and height in pixels of the given plane for an image of width x height, taking chroma subsampling into account.
+//Plane 1 of a paletted format is its palette, a single row of 256 entries of 4 bytes whatever the image size.
+func (d *PixFmtDescriptor) PlaneSize(plane, width, height int) (int, int) {
+	if plane == 1 && d.IsPaletted() {
+		return 256, 1
+	}
+	if plane == 1 || plane == 2 {
+		return ceilRShift(width, d.Log2ChromaW), ceilRShift(height, d.Log2ChromaH)
+	}
+	return width, height
+}
+
+func ceilRShift(a, b int) int {
+	return -((-a) >> uint(b))
+}
@@ -6,15 +6,60 @@ package avcodec
 //#cgo pkg-config: libavcodec libavutil
 //#include <libavcodec/avcodec.h>
 //#include <libavutil/pixfmt.h>
+//#include <libavutil/pixdesc.h>
+//#include <stdlib.h>
 import "C"
 import (
+	"fmt"
 	"unsafe"
 )
 
 const (
+	AV_PIX_FMT_NONE    = C.AV_PIX_FMT_NONE
 	AV_PIX_FMT_YUV420P = C.AV_PIX_FMT_YUV420P
 )
 
+//Return the short name for the pixel format, e.g. "yuv420p".
+func (p PixelFormat) String() string {
+	if p == AV_PIX_FMT_NONE {
+		return "none"
+	}
+	if s := C.av_get_pix_fmt_name((C.enum_AVPixelFormat)(p)); s != nil {
+		return C.GoString(s)
+	}
+	return fmt.Sprintf("PixelFormat(%d)", int(p))
+}
+
+//Return the pixel format matching the given name, the inverse of PixelFormat.String().
+func ParsePixelFormat(name string) (PixelFormat, error) {
+	if name == "none" {
+		return AV_PIX_FMT_NONE, nil
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	p := PixelFormat(C.av_get_pix_fmt(cname))
+	if p == AV_PIX_FMT_NONE {
+		return AV_PIX_FMT_NONE, fmt.Errorf("Unknown pixel format %q", name)
+	}
+	return p, nil
+}
+
+func (p PixelFormat) MarshalText() ([]byte, error) {
+	if p != AV_PIX_FMT_NONE && C.av_get_pix_fmt_name((C.enum_AVPixelFormat)(p)) == nil {
+		return nil, fmt.Errorf("Invalid pixel format %d", int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+func (p *PixelFormat) UnmarshalText(text []byte) error {
+	parsed, err := ParsePixelFormat(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
 //Utility function to access log2_chroma_w log2_chroma_h from the pixel format AvPixFmtDescriptor.
 func (p PixelFormat) AvcodecGetChromaSubSample(h, v *int) {
 	C.avcodec_get_chroma_sub_sample((C.enum_AVPixelFormat)(p), (*C.int)(unsafe.Pointer(h)), (*C.int)(unsafe.Pointer(v)))
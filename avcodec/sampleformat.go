@@ -0,0 +1,70 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+//#cgo pkg-config: libavutil
+//#include <libavutil/samplefmt.h>
+//#include <stdlib.h>
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+const (
+	AV_SAMPLE_FMT_NONE = C.AV_SAMPLE_FMT_NONE
+	AV_SAMPLE_FMT_U8   = C.AV_SAMPLE_FMT_U8
+	AV_SAMPLE_FMT_S16  = C.AV_SAMPLE_FMT_S16
+	AV_SAMPLE_FMT_S32  = C.AV_SAMPLE_FMT_S32
+	AV_SAMPLE_FMT_FLT  = C.AV_SAMPLE_FMT_FLT
+	AV_SAMPLE_FMT_DBL  = C.AV_SAMPLE_FMT_DBL
+	AV_SAMPLE_FMT_U8P  = C.AV_SAMPLE_FMT_U8P
+	AV_SAMPLE_FMT_S16P = C.AV_SAMPLE_FMT_S16P
+	AV_SAMPLE_FMT_S32P = C.AV_SAMPLE_FMT_S32P
+	AV_SAMPLE_FMT_FLTP = C.AV_SAMPLE_FMT_FLTP
+	AV_SAMPLE_FMT_DBLP = C.AV_SAMPLE_FMT_DBLP
+	AV_SAMPLE_FMT_S64  = C.AV_SAMPLE_FMT_S64
+	AV_SAMPLE_FMT_S64P = C.AV_SAMPLE_FMT_S64P
+)
+
+//Return the name of the sample format, e.g. "s16" or "fltp".
+func (f AvSampleFormat) String() string {
+	if f == AV_SAMPLE_FMT_NONE {
+		return "none"
+	}
+	if s := C.av_get_sample_fmt_name((C.enum_AVSampleFormat)(f)); s != nil {
+		return C.GoString(s)
+	}
+	return fmt.Sprintf("AvSampleFormat(%d)", int(f))
+}
+
+//Return the sample format matching the given name, the inverse of AvSampleFormat.String().
+func ParseSampleFormat(name string) (AvSampleFormat, error) {
+	if name == "none" {
+		return AV_SAMPLE_FMT_NONE, nil
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	f := AvSampleFormat(C.av_get_sample_fmt(cname))
+	if f == AV_SAMPLE_FMT_NONE {
+		return AV_SAMPLE_FMT_NONE, fmt.Errorf("Unknown sample format %q", name)
+	}
+	return f, nil
+}
+
+func (f AvSampleFormat) MarshalText() ([]byte, error) {
+	if f != AV_SAMPLE_FMT_NONE && C.av_get_sample_fmt_name((C.enum_AVSampleFormat)(f)) == nil {
+		return nil, fmt.Errorf("Invalid sample format %d", int(f))
+	}
+	return []byte(f.String()), nil
+}
+
+func (f *AvSampleFormat) UnmarshalText(text []byte) error {
+	parsed, err := ParseSampleFormat(string(text))
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
@@ -0,0 +1,127 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+/*
+#cgo pkg-config: libavutil
+#include <libavutil/samplefmt.h>
+#include <libavutil/frame.h>
+#include <libavutil/mem.h>
+
+static inline int goav_frame_channels(const AVFrame* frame)
+{
+#if LIBAVUTIL_VERSION_INT >= AV_VERSION_INT(57, 28, 100)
+	return frame->ch_layout.nb_channels;
+#else
+	return frame->channels;
+#endif
+}
+
+static inline int goav_samples_copy_buffer(AVFrame* frame, uint8_t* buf, int bufSize, int align, int toBuffer)
+{
+	int channels = goav_frame_channels(frame);
+	int planes = av_sample_fmt_is_planar(frame->format) ? channels : 1;
+	uint8_t** data = av_malloc_array(planes, sizeof(*data));
+	int size;
+	if (!data)
+		return AVERROR(ENOMEM);
+	size = av_samples_fill_arrays(data, NULL, buf, channels, frame->nb_samples, frame->format, align);
+	if (size > bufSize)
+		size = AVERROR(EINVAL);
+	if (size >= 0) {
+		if (toBuffer)
+			av_samples_copy(data, (void*)frame->extended_data, 0, 0, frame->nb_samples, channels, frame->format);
+		else
+			av_samples_copy(frame->extended_data, (void*)data, 0, 0, frame->nb_samples, channels, frame->format);
+	}
+	av_free(data);
+	return size;
+}
+*/
+import "C"
+import (
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+//Return number of bytes per sample, or zero if unknown.
+func (f AvSampleFormat) AvGetBytesPerSample() int {
+	return int(C.av_get_bytes_per_sample((C.enum_AVSampleFormat)(f)))
+}
+
+//Check if the sample format is planar.
+func (f AvSampleFormat) AvSampleFmtIsPlanar() int {
+	return int(C.av_sample_fmt_is_planar((C.enum_AVSampleFormat)(f)))
+}
+
+//Get the packed alternative form of the given sample format.
+func (f AvSampleFormat) AvGetPackedSampleFmt() AvSampleFormat {
+	return (AvSampleFormat)(C.av_get_packed_sample_fmt((C.enum_AVSampleFormat)(f)))
+}
+
+//Get the planar alternative form of the given sample format.
+func (f AvSampleFormat) AvGetPlanarSampleFmt() AvSampleFormat {
+	return (AvSampleFormat)(C.av_get_planar_sample_fmt((C.enum_AVSampleFormat)(f)))
+}
+
+//Get the required buffer size for the given audio parameters.
+func AvSamplesGetBufferSize(l *int32, c, n int, f AvSampleFormat, a int) int {
+	return int(C.av_samples_get_buffer_size((*C.int)(unsafe.Pointer(l)), C.int(c), C.int(n), (C.enum_AVSampleFormat)(f), C.int(a)))
+}
+
+//Fill plane data pointers and linesize for samples with sample format sample_fmt.
+func AvSamplesFillArrays(d **uint8, l *int32, b *uint8, c, n int, f AvSampleFormat, a int) int {
+	return int(C.av_samples_fill_arrays((**C.uint8_t)(unsafe.Pointer(d)), (*C.int)(unsafe.Pointer(l)), (*C.uint8_t)(b), C.int(c), C.int(n), (C.enum_AVSampleFormat)(f), C.int(a)))
+}
+
+//Allocate a samples buffer for nb_samples samples, and fill data pointers and linesize accordingly.
+//The allocated samples buffer has to be freed by using AvFreep(&d[0]).
+func AvSamplesAlloc(d **uint8, l *int32, c, n int, f AvSampleFormat, a int) int {
+	return int(C.av_samples_alloc((**C.uint8_t)(unsafe.Pointer(d)), (*C.int)(unsafe.Pointer(l)), C.int(c), C.int(n), (C.enum_AVSampleFormat)(f), C.int(a)))
+}
+
+//Copy samples from src to dst.
+func AvSamplesCopy(d, s **uint8, do, so, n, c int, f AvSampleFormat) int {
+	return int(C.av_samples_copy((**C.uint8_t)(unsafe.Pointer(d)), (**C.uint8_t)(unsafe.Pointer(s)), C.int(do), C.int(so), C.int(n), C.int(c), (C.enum_AVSampleFormat)(f)))
+}
+
+//Fill an audio buffer with silence.
+func AvSamplesSetSilence(d **uint8, o, n, c int, f AvSampleFormat) int {
+	return int(C.av_samples_set_silence((**C.uint8_t)(unsafe.Pointer(d)), C.int(o), C.int(n), C.int(c), (C.enum_AVSampleFormat)(f)))
+}
+
+//Pack the samples of an audio frame into one contiguous buffer, laid out as av_samples_fill_arrays() expects.
+//Planar formats are stored one channel after the other, packed formats are stored interleaved.
+func PackSamples(f *avutil.Frame, a int) ([]byte, error) {
+	cf := (*C.struct_AVFrame)(unsafe.Pointer(f))
+	size := AvSamplesGetBufferSize(nil, int(C.goav_frame_channels(cf)), int(cf.nb_samples), AvSampleFormat(cf.format), a)
+	if size < 0 {
+		return nil, &avutil.Error{Num: size}
+	}
+	buf := make([]byte, size)
+	if size == 0 {
+		return buf, nil
+	}
+	if ret := C.goav_samples_copy_buffer(cf, (*C.uint8_t)(unsafe.Pointer(&buf[0])), C.int(size), C.int(a), 1); ret < 0 {
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	return buf, nil
+}
+
+//Unpack a buffer produced by PackSamples() into an audio frame.
+//The frame format, channel layout and nb_samples must be set, and its buffers are allocated if needed.
+func UnpackSamples(f *avutil.Frame, buf []byte, a int) error {
+	if len(buf) == 0 {
+		return &avutil.Error{Num: avutil.AVERROR_EINVAL}
+	}
+	cf := (*C.struct_AVFrame)(unsafe.Pointer(f))
+	if err := makeFrameWritable(f); err != nil {
+		return err
+	}
+	if ret := C.goav_samples_copy_buffer(cf, (*C.uint8_t)(unsafe.Pointer(&buf[0])), C.int(len(buf)), C.int(a), 0); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+/*
+#cgo pkg-config: libavcodec
+#include <libavcodec/avcodec.h>
+#include <libavutil/mem.h>
+#include <string.h>
+*/
+import "C"
+import (
+	"image"
+	"image/color"
+	"time"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+type SubtitleType int
+
+const (
+	SUBTITLE_NONE   = SubtitleType(C.SUBTITLE_NONE)
+	SUBTITLE_BITMAP = SubtitleType(C.SUBTITLE_BITMAP)
+	SUBTITLE_TEXT   = SubtitleType(C.SUBTITLE_TEXT)
+	SUBTITLE_ASS    = SubtitleType(C.SUBTITLE_ASS)
+)
+
+const AV_SUBTITLE_FLAG_FORCED = int(C.AV_SUBTITLE_FLAG_FORCED)
+
+func (t SubtitleType) String() string {
+	switch t {
+	case SUBTITLE_BITMAP:
+		return "bitmap"
+	case SUBTITLE_TEXT:
+		return "text"
+	case SUBTITLE_ASS:
+		return "ass"
+	}
+	return "none"
+}
+
+//SubtitleRect is a region of a subtitle, holding a bitmap, a plain text or an ASS dialogue line depending on its type.
+type SubtitleRect struct {
+	Type SubtitleType
+	//Plain text of a SUBTITLE_TEXT rect.
+	Text string
+	//ASS dialogue line of a SUBTITLE_ASS rect, in the "ReadOrder,Layer,Style,Name,MarginL,MarginR,MarginV,Effect,Text" form of the decoders.
+	Ass string
+	//Position of a SUBTITLE_BITMAP rect in the video, and its image, whose bounds start at 0,0.
+	X, Y  int
+	Image *image.Paletted
+	//Whether the rect must be displayed even when the subtitles are turned off.
+	Forced bool
+}
+
+//Subtitle is a decoded subtitle, displayed from StartDisplayTime to EndDisplayTime after Pts.
+type Subtitle struct {
+	//Presentation timestamp in AV_TIME_BASE units, AV_NOPTS_VALUE when unknown.
+	Pts                              int64
+	StartDisplayTime, EndDisplayTime time.Duration
+	//0 for bitmap subtitles, 1 for text ones.
+	Format int
+	Rects  []SubtitleRect
+}
+
+//Return the time the subtitle starts being displayed at in AV_TIME_BASE units, AV_NOPTS_VALUE when unknown.
+func (s *Subtitle) Start() int64 {
+	if s.Pts == avutil.AV_NOPTS_VALUE {
+		return s.Pts
+	}
+	return s.Pts + int64(s.StartDisplayTime/time.Microsecond)
+}
+
+//Return the time the subtitle stops being displayed at in AV_TIME_BASE units, AV_NOPTS_VALUE when unknown.
+func (s *Subtitle) End() int64 {
+	if s.Pts == avutil.AV_NOPTS_VALUE {
+		return s.Pts
+	}
+	return s.Pts + int64(s.EndDisplayTime/time.Microsecond)
+}
+
+func (s *AvSubtitle) Format() int {
+	return int(s.format)
+}
+
+//Return the time the subtitle starts being displayed at, relative to Pts().
+func (s *AvSubtitle) StartDisplayTime() time.Duration {
+	return time.Duration(s.start_display_time) * time.Millisecond
+}
+
+//Return the time the subtitle stops being displayed at, relative to Pts().
+func (s *AvSubtitle) EndDisplayTime() time.Duration {
+	return time.Duration(s.end_display_time) * time.Millisecond
+}
+
+//Return the presentation timestamp in AV_TIME_BASE units.
+func (s *AvSubtitle) Pts() int64 {
+	return int64(s.pts)
+}
+
+func (s *AvSubtitle) NumRects() int {
+	return int(s.num_rects)
+}
+
+func (s *AvSubtitle) Rects() []*AvSubtitleRect {
+	if s.num_rects == 0 {
+		return nil
+	}
+	rects := (*[1 << 16]*AvSubtitleRect)(unsafe.Pointer(s.rects))[:s.num_rects:s.num_rects]
+	return append([]*AvSubtitleRect(nil), rects...)
+}
+
+//Return a copy of the subtitle with Go types.
+func (s *AvSubtitle) Subtitle() *Subtitle {
+	sub := &Subtitle{
+		Pts:              s.Pts(),
+		StartDisplayTime: s.StartDisplayTime(),
+		EndDisplayTime:   s.EndDisplayTime(),
+		Format:           s.Format(),
+	}
+	for _, r := range s.Rects() {
+		sub.Rects = append(sub.Rects, r.Rect())
+	}
+	return sub
+}
+
+func (r *AvSubtitleRect) Type() SubtitleType {
+	return SubtitleType(r._type)
+}
+
+func (r *AvSubtitleRect) Text() string {
+	return C.GoString(r.text)
+}
+
+func (r *AvSubtitleRect) Ass() string {
+	return C.GoString(r.ass)
+}
+
+func (r *AvSubtitleRect) X() int {
+	return int(r.x)
+}
+
+func (r *AvSubtitleRect) Y() int {
+	return int(r.y)
+}
+
+func (r *AvSubtitleRect) W() int {
+	return int(r.w)
+}
+
+func (r *AvSubtitleRect) H() int {
+	return int(r.h)
+}
+
+func (r *AvSubtitleRect) NbColors() int {
+	return int(r.nb_colors)
+}
+
+func (r *AvSubtitleRect) Flags() int {
+	return int(r.flags)
+}
+
+//Return a copy of the bitmap of the rect, with the palette converted from the ARGB one of libavcodec, nil if there is none.
+func (r *AvSubtitleRect) Image() *image.Paletted {
+	if r.data[0] == nil || r.w <= 0 || r.h <= 0 {
+		return nil
+	}
+	//The palette of libavcodec has 256 entries, whatever the number of colors claims.
+	colors := int(r.nb_colors)
+	if colors > 256 {
+		colors = 256
+	} else if colors < 0 || r.data[1] == nil {
+		colors = 0
+	}
+	palette := make(color.Palette, colors)
+	if colors > 0 {
+		argb := (*[256]uint32)(unsafe.Pointer(r.data[1]))[:colors:colors]
+		for i, c := range argb {
+			palette[i] = color.NRGBA{R: uint8(c >> 16), G: uint8(c >> 8), B: uint8(c), A: uint8(c >> 24)}
+		}
+	}
+	w, h, stride := int(r.w), int(r.h), int(r.linesize[0])
+	img := image.NewPaletted(image.Rect(0, 0, w, h), palette)
+	src := (*[1 << 30]byte)(unsafe.Pointer(r.data[0]))[: (h-1)*stride+w : (h-1)*stride+w]
+	for y := 0; y < h; y++ {
+		copy(img.Pix[y*img.Stride:y*img.Stride+w], src[y*stride:y*stride+w])
+	}
+	return img
+}
+
+//Return a copy of the rect with Go types.
+func (r *AvSubtitleRect) Rect() SubtitleRect {
+	return SubtitleRect{
+		Type:   r.Type(),
+		Text:   r.Text(),
+		Ass:    r.Ass(),
+		X:      r.X(),
+		Y:      r.Y(),
+		Image:  r.Image(),
+		Forced: r.flags&C.AV_SUBTITLE_FLAG_FORCED != 0,
+	}
+}
+
+//Fill c with the subtitle, the allocated fields being freed with AvsubtitleFree().
+func (s *Subtitle) fill(c *C.struct_AVSubtitle) error {
+	*c = C.struct_AVSubtitle{}
+	c.format = C.uint16_t(s.Format)
+	c.start_display_time = C.uint32_t(s.StartDisplayTime / time.Millisecond)
+	c.end_display_time = C.uint32_t(s.EndDisplayTime / time.Millisecond)
+	c.pts = C.int64_t(s.Pts)
+	if len(s.Rects) == 0 {
+		return nil
+	}
+	c.rects = (**C.struct_AVSubtitleRect)(C.av_mallocz(C.size_t(len(s.Rects)) * C.size_t(unsafe.Sizeof(uintptr(0)))))
+	if c.rects == nil {
+		return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	rects := (*[1 << 16]*C.struct_AVSubtitleRect)(unsafe.Pointer(c.rects))[:len(s.Rects):len(s.Rects)]
+	for i := range s.Rects {
+		rects[i] = (*C.struct_AVSubtitleRect)(C.av_mallocz(C.sizeof_struct_AVSubtitleRect))
+		if rects[i] == nil {
+			return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+		}
+		c.num_rects++
+		if err := s.Rects[i].fill(rects[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SubtitleRect) fill(c *C.struct_AVSubtitleRect) error {
+	c._type = C.enum_AVSubtitleType(r.Type)
+	if r.Forced {
+		c.flags |= C.AV_SUBTITLE_FLAG_FORCED
+	}
+	if r.Text != "" {
+		if c.text = avStrdup(r.Text); c.text == nil {
+			return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+		}
+	}
+	if r.Ass != "" {
+		if c.ass = avStrdup(r.Ass); c.ass == nil {
+			return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+		}
+	}
+	if r.Image == nil {
+		return nil
+	}
+	b := r.Image.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if len(r.Image.Palette) > 256 {
+		return &avutil.Error{Num: avutil.AVERROR_EINVAL}
+	}
+	c.x, c.y, c.w, c.h = C.int(r.X), C.int(r.Y), C.int(w), C.int(h)
+	c.nb_colors = C.int(len(r.Image.Palette))
+	c.data[0] = (*C.uint8_t)(C.av_mallocz(C.size_t(w * h)))
+	c.data[1] = (*C.uint8_t)(C.av_mallocz(256 * 4))
+	if c.data[0] == nil || c.data[1] == nil {
+		return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	c.linesize[0] = C.int(w)
+	if w > 0 && h > 0 {
+		dst := (*[1 << 30]byte)(unsafe.Pointer(c.data[0]))[: w*h : w*h]
+		for y := 0; y < h; y++ {
+			i := r.Image.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(dst[y*w:(y+1)*w], r.Image.Pix[i:i+w])
+		}
+	}
+	argb := (*[256]uint32)(unsafe.Pointer(c.data[1]))
+	for i, pc := range r.Image.Palette {
+		n := color.NRGBAModel.Convert(pc).(color.NRGBA)
+		argb[i] = uint32(n.A)<<24 | uint32(n.R)<<16 | uint32(n.G)<<8 | uint32(n.B)
+	}
+	return nil
+}
+
+func avStrdup(s string) *C.char {
+	cs := (*C.char)(C.av_malloc(C.size_t(len(s) + 1)))
+	if cs != nil {
+		b := (*[1 << 30]byte)(unsafe.Pointer(cs))[: len(s)+1 : len(s)+1]
+		copy(b, s)
+		b[len(s)] = 0
+	}
+	return cs
+}
@@ -0,0 +1,325 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avcodec
+
+/*
+#cgo pkg-config: libavcodec
+#include <libavcodec/avcodec.h>
+#include <libavutil/opt.h>
+#include <stdlib.h>
+#include <string.h>
+
+static inline void goav_packet_set_timing(AVPacket *p, int64_t pts, int64_t duration)
+{
+	p->pts = p->dts = pts;
+	p->duration = duration;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+//DefaultASSHeader is the ASS script header given to text subtitle encoders when none is set,
+//with a single Default style as libavcodec uses for the text decoders.
+const DefaultASSHeader = "[Script Info]\r\n" +
+	"ScriptType: v4.00+\r\n" +
+	"PlayResX: 384\r\n" +
+	"PlayResY: 288\r\n" +
+	"ScaledBorderAndShadow: yes\r\n" +
+	"YCbCr Matrix: None\r\n" +
+	"\r\n" +
+	"[V4+ Styles]\r\n" +
+	"Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\r\n" +
+	"Style: Default,Arial,16,&Hffffff,&Hffffff,&H0,&H0,0,0,0,0,100,100,0,0,1,1,0,2,10,10,10,1\r\n" +
+	"\r\n" +
+	"[Events]\r\n" +
+	"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\r\n"
+
+//Find the subtitle decoder or encoder of the codec and allocate a context for it.
+func newSubtitleContext(id CodecId, encoder bool) (*C.struct_AVCodecContext, error) {
+	var codec *C.struct_AVCodec
+	if encoder {
+		codec = C.avcodec_find_encoder((C.enum_AVCodecID)(id))
+	} else {
+		codec = C.avcodec_find_decoder((C.enum_AVCodecID)(id))
+	}
+	if codec == nil {
+		kind := "decoder"
+		if encoder {
+			kind = "encoder"
+		}
+		return nil, fmt.Errorf("No %s for codec %q", kind, id)
+	}
+	if codec._type != C.AVMEDIA_TYPE_SUBTITLE {
+		return nil, fmt.Errorf("Codec %q is not a subtitle codec", id)
+	}
+	ctx := C.avcodec_alloc_context3(codec)
+	if ctx == nil {
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	return ctx, nil
+}
+
+func isTextSubtitle(id CodecId) bool {
+	d := C.avcodec_descriptor_get((C.enum_AVCodecID)(id))
+	return d != nil && d.props&C.AV_CODEC_PROP_TEXT_SUB != 0
+}
+
+//SubtitleDecoder decodes subtitle packets, such as SRT, WebVTT, ASS and mov_text ones or DVB, DVD and PGS bitmaps, into Subtitles.
+type SubtitleDecoder struct {
+	ctx *C.struct_AVCodecContext
+}
+
+//Create a decoder for subtitles of the given codec parameters, e.g. those of a demuxed stream, whose packets have timestamps in timeBase.
+func NewSubtitleDecoder(par *CodecParameters, timeBase avutil.Rational) (*SubtitleDecoder, error) {
+	cpar := (*C.struct_AVCodecParameters)(par)
+	ctx, err := newSubtitleContext(CodecId(cpar.codec_id), false)
+	if err != nil {
+		return nil, err
+	}
+	d := &SubtitleDecoder{ctx: ctx}
+	if ret := C.avcodec_parameters_to_context(ctx, cpar); ret < 0 {
+		d.Free()
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	ctx.pkt_timebase = *(*C.AVRational)(unsafe.Pointer(&timeBase))
+	if ret := C.avcodec_open2(ctx, ctx.codec, nil); ret < 0 {
+		d.Free()
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	return d, nil
+}
+
+//Decode a packet and return the subtitle it completes, nil if there is none yet.
+//The timestamps of the subtitle are taken from the packet, and its end display time from the packet duration when the codec has none.
+func (d *SubtitleDecoder) Decode(p *Packet) (*Subtitle, error) {
+	var sub C.struct_AVSubtitle
+	var got C.int
+	if ret := C.avcodec_decode_subtitle2(d.ctx, &sub, &got, (*C.struct_AVPacket)(p)); ret < 0 {
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	if got == 0 {
+		return nil, nil
+	}
+	defer C.avsubtitle_free(&sub)
+	return (*AvSubtitle)(&sub).Subtitle(), nil
+}
+
+//Return the subtitle the decoder still held at the end of the stream, nil if there is none.
+func (d *SubtitleDecoder) Flush() (*Subtitle, error) {
+	if d.ctx.codec.capabilities&C.AV_CODEC_CAP_DELAY == 0 {
+		return nil, nil
+	}
+	p := AvPacketAlloc()
+	defer AvPacketFree(p)
+	return d.Decode(p)
+}
+
+//Return the ASS script header with the styles of the decoded text subtitles, to give to an encoder, empty for bitmap subtitles.
+func (d *SubtitleDecoder) Header() string {
+	if d.ctx.subtitle_header == nil {
+		return ""
+	}
+	return C.GoStringN((*C.char)(unsafe.Pointer(d.ctx.subtitle_header)), d.ctx.subtitle_header_size)
+}
+
+//Return the underlying codec context.
+func (d *SubtitleDecoder) Context() *Context {
+	return (*Context)(unsafe.Pointer(d.ctx))
+}
+
+func (d *SubtitleDecoder) Free() {
+	C.avcodec_free_context(&d.ctx)
+}
+
+//SubtitleEncoderOptions configures a SubtitleEncoder.
+type SubtitleEncoderOptions struct {
+	//Time base of the timestamps of the packets, 1/1000 when zero.
+	TimeBase avutil.Rational
+	//ASS script header with the styles of text subtitles, such as SubtitleDecoder.Header(), DefaultASSHeader when empty.
+	Header string
+	//Size of the video the bitmap subtitles are shown on, required by the DVB and DVD encoders.
+	Width, Height int
+	//Other options of the encoder, by name.
+	Options map[string]string
+}
+
+//SubtitleEncoder encodes Subtitles into packets, e.g. SRT, WebVTT, ASS or mov_text ones from text subtitles,
+//or DVB and DVD ones from bitmap subtitles such as decoded PGS ones.
+//libavcodec cannot convert text subtitles into bitmaps or the reverse.
+type SubtitleEncoder struct {
+	ctx       *C.struct_AVCodecContext
+	text      bool
+	readOrder int
+	buf       *C.uint8_t
+}
+
+//Size of the buffer subtitles are encoded into, as in ffmpeg.
+const subtitleBufferSize = 1024 * 1024
+
+//Create an encoder for subtitles of the given codec.
+func NewSubtitleEncoder(id CodecId, opts SubtitleEncoderOptions) (*SubtitleEncoder, error) {
+	ctx, err := newSubtitleContext(id, true)
+	if err != nil {
+		return nil, err
+	}
+	e := &SubtitleEncoder{ctx: ctx, text: isTextSubtitle(id)}
+	if opts.TimeBase.Num() <= 0 || opts.TimeBase.Den() <= 0 {
+		opts.TimeBase = avutil.NewRational(1, 1000)
+	}
+	ctx.time_base = *(*C.AVRational)(unsafe.Pointer(&opts.TimeBase))
+	ctx.width, ctx.height = C.int(opts.Width), C.int(opts.Height)
+	if e.text {
+		header := opts.Header
+		if header == "" {
+			header = DefaultASSHeader
+		}
+		//The context frees the header.
+		ctx.subtitle_header = (*C.uint8_t)(unsafe.Pointer(avStrdup(header)))
+		if ctx.subtitle_header == nil {
+			e.Free()
+			return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+		}
+		ctx.subtitle_header_size = C.int(len(header))
+	}
+	for name, value := range opts.Options {
+		if err := e.setOption(name, value); err != nil {
+			e.Free()
+			return nil, err
+		}
+	}
+	if ret := C.avcodec_open2(ctx, ctx.codec, nil); ret < 0 {
+		e.Free()
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	if e.buf = (*C.uint8_t)(C.av_malloc(subtitleBufferSize)); e.buf == nil {
+		e.Free()
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	return e, nil
+}
+
+func (e *SubtitleEncoder) setOption(name, value string) error {
+	cname, cvalue := C.CString(name), C.CString(value)
+	defer C.free(unsafe.Pointer(cname))
+	defer C.free(unsafe.Pointer(cvalue))
+	ret := C.av_opt_set(unsafe.Pointer(e.ctx), cname, cvalue, C.AV_OPT_SEARCH_CHILDREN)
+	if ret == C.AVERROR_OPTION_NOT_FOUND {
+		return fmt.Errorf("Unknown subtitle encoder option %q", name)
+	}
+	if ret < 0 {
+		return fmt.Errorf("Invalid value %q for subtitle encoder option %s", value, name)
+	}
+	return nil
+}
+
+//Encode a subtitle into packets with timestamps in the time base of the encoder, owned by the caller and freed with AvPacketFree().
+//A DVB subtitle gives two packets, the second one clearing it at its end display time.
+//Plain text rects are turned into ASS dialogue lines with the Default style for the text encoders, which only take those.
+func (e *SubtitleEncoder) Encode(s *Subtitle) ([]*Packet, error) {
+	if s.Pts == avutil.AV_NOPTS_VALUE {
+		return nil, fmt.Errorf("Subtitle without timestamp")
+	}
+	//Encode from the start of the display, as ffmpeg does.
+	sub := *s
+	sub.Pts = s.Start()
+	sub.StartDisplayTime, sub.EndDisplayTime = 0, s.EndDisplayTime-s.StartDisplayTime
+	if e.text {
+		sub.Format = 1
+		sub.Rects = make([]SubtitleRect, len(s.Rects))
+		for i, r := range s.Rects {
+			if r.Type == SUBTITLE_TEXT && r.Ass == "" {
+				r.Type, r.Ass = SUBTITLE_ASS, e.dialogue(r.Text)
+			}
+			sub.Rects[i] = r
+		}
+	}
+	var csub C.struct_AVSubtitle
+	defer C.avsubtitle_free(&csub)
+	if err := sub.fill(&csub); err != nil {
+		return nil, err
+	}
+	tb := *(*avutil.Rational)(unsafe.Pointer(&e.ctx.time_base))
+	ms := avutil.NewRational(1, 1000)
+	n := 1
+	if e.ctx.codec_id == C.AV_CODEC_ID_DVB_SUBTITLE {
+		n = 2
+	}
+	var packets []*Packet
+	numRects := csub.num_rects
+	for i := 0; i < n; i++ {
+		if i == 1 {
+			csub.num_rects = 0
+		}
+		size := C.avcodec_encode_subtitle(e.ctx, e.buf, subtitleBufferSize, &csub)
+		csub.num_rects = numRects
+		if size < 0 {
+			freePackets(packets)
+			return nil, &avutil.Error{Num: int(size)}
+		}
+		if size == 0 {
+			continue
+		}
+		p := AvPacketAlloc()
+		if p == nil || C.av_new_packet((*C.struct_AVPacket)(p), size) < 0 {
+			AvPacketFree(p)
+			freePackets(packets)
+			return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+		}
+		cp := (*C.struct_AVPacket)(p)
+		C.memcpy(unsafe.Pointer(cp.data), unsafe.Pointer(e.buf), C.size_t(size))
+		pts := avutil.AvRescaleQ(sub.Pts, avutil.AvGetTimeBaseQ(), tb)
+		duration := avutil.AvRescaleQ(int64(sub.EndDisplayTime/time.Millisecond), ms, tb)
+		if i == 1 {
+			pts += duration
+		}
+		C.goav_packet_set_timing(cp, C.int64_t(pts), C.int64_t(duration))
+		cp.flags |= C.AV_PKT_FLAG_KEY
+		packets = append(packets, p)
+	}
+	return packets, nil
+}
+
+//Return an ASS dialogue line for a plain text, in the form of the decoders.
+func (e *SubtitleEncoder) dialogue(text string) string {
+	text = strings.NewReplacer("\r\n", "\\N", "\n", "\\N", "{", "\\{", "}", "\\}").Replace(text)
+	line := fmt.Sprintf("%d,0,Default,,0,0,0,,%s", e.readOrder, text)
+	e.readOrder++
+	return line
+}
+
+func freePackets(packets []*Packet) {
+	for _, p := range packets {
+		AvPacketFree(p)
+	}
+}
+
+//Return the time base of the timestamps of the packets.
+func (e *SubtitleEncoder) TimeBase() avutil.Rational {
+	return *(*avutil.Rational)(unsafe.Pointer(&e.ctx.time_base))
+}
+
+//Copy the codec parameters of the encoded subtitles into par, e.g. those of an output stream.
+func (e *SubtitleEncoder) CopyParameters(par *CodecParameters) error {
+	if ret := C.avcodec_parameters_from_context((*C.struct_AVCodecParameters)(par), e.ctx); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
+
+//Return the underlying codec context.
+func (e *SubtitleEncoder) Context() *Context {
+	return (*Context)(unsafe.Pointer(e.ctx))
+}
+
+func (e *SubtitleEncoder) Free() {
+	C.av_free(unsafe.Pointer(e.buf))
+	C.avcodec_free_context(&e.ctx)
+}
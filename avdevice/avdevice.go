@@ -10,6 +10,7 @@ package avdevice
 /*
 	#cgo pkg-config: libavdevice
 	#include <libavdevice/avdevice.h>
+	#include <stdlib.h>
 */
 import "C"
 import (
@@ -49,14 +50,14 @@ func AvdeviceRegisterAll() {
 	C.avdevice_register_all()
 }
 
-//Send control message from application to device.
-func AvdeviceAppToDevControlMessage(s *AvFormatContext, m AvAppToDevMessageType, da int, d uintptr) int {
-	return int(C.avdevice_app_to_dev_control_message((*C.struct_AVFormatContext)(s), (C.enum_AVAppToDevMessageType)(m), unsafe.Pointer(&da), C.size_t(d)))
+//Send control message from application to device, with the data the message type expects, nil for none.
+func AvdeviceAppToDevControlMessage(s *AvFormatContext, m AvAppToDevMessageType, data unsafe.Pointer, size uintptr) int {
+	return int(C.avdevice_app_to_dev_control_message((*C.struct_AVFormatContext)(s), (C.enum_AVAppToDevMessageType)(m), data, C.size_t(size)))
 }
 
-//Send control message from device to application.
-func AvdeviceDevToAppControlMessage(fcxt *AvFormatContext, m AvDevToAppMessageType, da int, d uintptr) int {
-	return int(C.avdevice_dev_to_app_control_message((*C.struct_AVFormatContext)(fcxt), (C.enum_AVDevToAppMessageType)(m), unsafe.Pointer(&da), C.size_t(d)))
+//Send control message from device to application, with the data the message type expects, nil for none.
+func AvdeviceDevToAppControlMessage(s *AvFormatContext, m AvDevToAppMessageType, data unsafe.Pointer, size uintptr) int {
+	return int(C.avdevice_dev_to_app_control_message((*C.struct_AVFormatContext)(s), (C.enum_AVDevToAppMessageType)(m), data, C.size_t(size)))
 }
 
 //Initialize capabilities probing API based on AvOption API.
@@ -79,13 +80,22 @@ func AvdeviceFreeListDevices(d **AvDeviceInfoList) {
 	C.avdevice_free_list_devices((**C.struct_AVDeviceInfoList)(unsafe.Pointer(d)))
 }
 
-// //int 	avdevice_list_input_sources (struct InputFormat *device, const char *device_name, Dictionary *device_options, AvDeviceInfoList **device_list)
-// //List devices.
-// func AvdeviceListInputSources(d *InputFormat, dv string, do *Dictionary, dl **AvDeviceInfoList) int {
-// 	return int(C.avdevice_list_input_sources((*C.struct_AVInputFormat)(d), C.CString(dv), (*C.struct_AVDictionary)(do), (**C.struct_AVDeviceInfoList)(unsafe.Pointer(dl))))
-// }
+//List the sources of an input device.
+func AvdeviceListInputSources(d *InputFormat, dn string, do *Dictionary, dl **AvDeviceInfoList) int {
+	var cdn *C.char
+	if dn != "" {
+		cdn = C.CString(dn)
+		defer C.free(unsafe.Pointer(cdn))
+	}
+	return int(C.avdevice_list_input_sources((*C.struct_AVInputFormat)(d), cdn, (*C.struct_AVDictionary)(do), (**C.struct_AVDeviceInfoList)(unsafe.Pointer(dl))))
+}
 
-// //int 	avdevice_list_output_sinks (struct OutputFormat *device, const char *device_name, Dictionary *device_options, AvDeviceInfoList **device_list)
-// func AvdeviceListOutputSinks(d *OutputFormat, dn string, di *Dictionary, dl **AvDeviceInfoList) int {
-// 	return int(C.avdevice_list_output_sinks((*C.struct_AVOutputFormat)(d), C.CString(dn), (*C.struct_AVDictionary)(di), (**C.struct_AVDeviceInfoList)(unsafe.Pointer(dl))))
-// }
+//List the sinks of an output device.
+func AvdeviceListOutputSinks(d *OutputFormat, dn string, do *Dictionary, dl **AvDeviceInfoList) int {
+	var cdn *C.char
+	if dn != "" {
+		cdn = C.CString(dn)
+		defer C.free(unsafe.Pointer(cdn))
+	}
+	return int(C.avdevice_list_output_sinks((*C.struct_AVOutputFormat)(d), cdn, (*C.struct_AVDictionary)(do), (**C.struct_AVDeviceInfoList)(unsafe.Pointer(dl))))
+}
@@ -0,0 +1,333 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avdevice
+
+/*
+#cgo pkg-config: libavdevice libavformat
+#include <libavdevice/avdevice.h>
+#include <libavformat/avformat.h>
+
+extern int goavDeviceControlMessage(struct AVFormatContext* s, int type, void* data, size_t dataSize);
+
+static inline void goav_set_control_message_cb(AVFormatContext* s, int set)
+{
+	s->control_message_cb = set ? goavDeviceControlMessage : NULL;
+}
+
+static inline int goav_has_control_message_cb(AVFormatContext* s)
+{
+	return s->control_message_cb == goavDeviceControlMessage;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"image"
+	"sync"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avformat"
+	"github.com/alon-ne/goav/avutil"
+)
+
+const (
+	AV_APP_TO_DEV_NONE           = AvAppToDevMessageType(C.AV_APP_TO_DEV_NONE)
+	AV_APP_TO_DEV_WINDOW_SIZE    = AvAppToDevMessageType(C.AV_APP_TO_DEV_WINDOW_SIZE)
+	AV_APP_TO_DEV_WINDOW_REPAINT = AvAppToDevMessageType(C.AV_APP_TO_DEV_WINDOW_REPAINT)
+	AV_APP_TO_DEV_PAUSE          = AvAppToDevMessageType(C.AV_APP_TO_DEV_PAUSE)
+	AV_APP_TO_DEV_PLAY           = AvAppToDevMessageType(C.AV_APP_TO_DEV_PLAY)
+	AV_APP_TO_DEV_TOGGLE_PAUSE   = AvAppToDevMessageType(C.AV_APP_TO_DEV_TOGGLE_PAUSE)
+	AV_APP_TO_DEV_SET_VOLUME     = AvAppToDevMessageType(C.AV_APP_TO_DEV_SET_VOLUME)
+	AV_APP_TO_DEV_MUTE           = AvAppToDevMessageType(C.AV_APP_TO_DEV_MUTE)
+	AV_APP_TO_DEV_UNMUTE         = AvAppToDevMessageType(C.AV_APP_TO_DEV_UNMUTE)
+	AV_APP_TO_DEV_TOGGLE_MUTE    = AvAppToDevMessageType(C.AV_APP_TO_DEV_TOGGLE_MUTE)
+	AV_APP_TO_DEV_GET_VOLUME     = AvAppToDevMessageType(C.AV_APP_TO_DEV_GET_VOLUME)
+	AV_APP_TO_DEV_GET_MUTE       = AvAppToDevMessageType(C.AV_APP_TO_DEV_GET_MUTE)
+)
+
+const (
+	AV_DEV_TO_APP_NONE                  = AvDevToAppMessageType(C.AV_DEV_TO_APP_NONE)
+	AV_DEV_TO_APP_CREATE_WINDOW_BUFFER  = AvDevToAppMessageType(C.AV_DEV_TO_APP_CREATE_WINDOW_BUFFER)
+	AV_DEV_TO_APP_PREPARE_WINDOW_BUFFER = AvDevToAppMessageType(C.AV_DEV_TO_APP_PREPARE_WINDOW_BUFFER)
+	AV_DEV_TO_APP_DISPLAY_WINDOW_BUFFER = AvDevToAppMessageType(C.AV_DEV_TO_APP_DISPLAY_WINDOW_BUFFER)
+	AV_DEV_TO_APP_DESTROY_WINDOW_BUFFER = AvDevToAppMessageType(C.AV_DEV_TO_APP_DESTROY_WINDOW_BUFFER)
+	AV_DEV_TO_APP_BUFFER_OVERFLOW       = AvDevToAppMessageType(C.AV_DEV_TO_APP_BUFFER_OVERFLOW)
+	AV_DEV_TO_APP_BUFFER_UNDERFLOW      = AvDevToAppMessageType(C.AV_DEV_TO_APP_BUFFER_UNDERFLOW)
+	AV_DEV_TO_APP_BUFFER_READABLE       = AvDevToAppMessageType(C.AV_DEV_TO_APP_BUFFER_READABLE)
+	AV_DEV_TO_APP_BUFFER_WRITABLE       = AvDevToAppMessageType(C.AV_DEV_TO_APP_BUFFER_WRITABLE)
+	AV_DEV_TO_APP_MUTE_STATE_CHANGED    = AvDevToAppMessageType(C.AV_DEV_TO_APP_MUTE_STATE_CHANGED)
+	AV_DEV_TO_APP_VOLUME_LEVEL_CHANGED  = AvDevToAppMessageType(C.AV_DEV_TO_APP_VOLUME_LEVEL_CHANGED)
+)
+
+func (t AvDevToAppMessageType) String() string {
+	switch t {
+	case AV_DEV_TO_APP_CREATE_WINDOW_BUFFER:
+		return "create window buffer"
+	case AV_DEV_TO_APP_PREPARE_WINDOW_BUFFER:
+		return "prepare window buffer"
+	case AV_DEV_TO_APP_DISPLAY_WINDOW_BUFFER:
+		return "display window buffer"
+	case AV_DEV_TO_APP_DESTROY_WINDOW_BUFFER:
+		return "destroy window buffer"
+	case AV_DEV_TO_APP_BUFFER_OVERFLOW:
+		return "buffer overflow"
+	case AV_DEV_TO_APP_BUFFER_UNDERFLOW:
+		return "buffer underflow"
+	case AV_DEV_TO_APP_BUFFER_READABLE:
+		return "buffer readable"
+	case AV_DEV_TO_APP_BUFFER_WRITABLE:
+		return "buffer writable"
+	case AV_DEV_TO_APP_MUTE_STATE_CHANGED:
+		return "mute state changed"
+	case AV_DEV_TO_APP_VOLUME_LEVEL_CHANGED:
+		return "volume level changed"
+	}
+	return "none"
+}
+
+//DeviceMessage is a message sent by a device to the application, the fields besides Type being set depending on it.
+type DeviceMessage struct {
+	Type AvDevToAppMessageType
+	//Preferred size of the window buffer of AV_DEV_TO_APP_CREATE_WINDOW_BUFFER, nil when the device has none.
+	Window *image.Rectangle
+	//Bytes available to read or write of AV_DEV_TO_APP_BUFFER_READABLE and AV_DEV_TO_APP_BUFFER_WRITABLE, -1 when unknown.
+	Bytes int64
+	//Mute state of AV_DEV_TO_APP_MUTE_STATE_CHANGED.
+	Muted bool
+	//Volume of AV_DEV_TO_APP_VOLUME_LEVEL_CHANGED, from 0 to 1.
+	Volume float64
+}
+
+//Return the device context of a format context opened with the avformat package.
+func FromFormatContext(s *avformat.Context) *AvFormatContext {
+	return (*AvFormatContext)(unsafe.Pointer(s))
+}
+
+//Ask the device to pause its playback, mostly useful with devices that buffer, which are not paused by default.
+func (s *AvFormatContext) Pause() error {
+	return s.control(AV_APP_TO_DEV_PAUSE, nil, 0)
+}
+
+func (s *AvFormatContext) Play() error {
+	return s.control(AV_APP_TO_DEV_PLAY, nil, 0)
+}
+
+func (s *AvFormatContext) TogglePause() error {
+	return s.control(AV_APP_TO_DEV_TOGGLE_PAUSE, nil, 0)
+}
+
+func (s *AvFormatContext) Mute() error {
+	return s.control(AV_APP_TO_DEV_MUTE, nil, 0)
+}
+
+func (s *AvFormatContext) Unmute() error {
+	return s.control(AV_APP_TO_DEV_UNMUTE, nil, 0)
+}
+
+func (s *AvFormatContext) ToggleMute() error {
+	return s.control(AV_APP_TO_DEV_TOGGLE_MUTE, nil, 0)
+}
+
+//Set the volume of the device, from 0 to 1, for its stream when possible or else system wide.
+func (s *AvFormatContext) SetVolume(volume float64) error {
+	if volume < 0 || volume > 1 {
+		return fmt.Errorf("Invalid volume %g, must be between 0 and 1", volume)
+	}
+	v := C.double(volume)
+	return s.control(AV_APP_TO_DEV_SET_VOLUME, unsafe.Pointer(&v), unsafe.Sizeof(v))
+}
+
+//Return the volume of the device, from 0 to 1, as it reports it when asked.
+//Devices only report changes, so the volume last reported for the context is returned when the device reports nothing new.
+//The message handler, if any, receives the report as well.
+func (s *AvFormatContext) Volume() (float64, error) {
+	var volume float64
+	err := s.query(AV_APP_TO_DEV_GET_VOLUME, func(h *messageHandler) bool {
+		volume = h.volume
+		return h.hasVolume
+	})
+	return volume, err
+}
+
+//Return whether the device is muted, as it reports it when asked.
+//Devices only report changes, so the state last reported for the context is returned when the device reports nothing new.
+//The message handler, if any, receives the report as well.
+func (s *AvFormatContext) Muted() (bool, error) {
+	var muted bool
+	err := s.query(AV_APP_TO_DEV_GET_MUTE, func(h *messageHandler) bool {
+		muted = h.muted
+		return h.hasMuted
+	})
+	return muted, err
+}
+
+//Tell the device the size of the window it renders to, after the application created or resized it.
+func (s *AvFormatContext) SetWindowSize(r image.Rectangle) error {
+	rect := deviceRect(r)
+	return s.control(AV_APP_TO_DEV_WINDOW_SIZE, unsafe.Pointer(&rect), unsafe.Sizeof(rect))
+}
+
+//Ask the device to repaint an area of its window, the whole window if r is nil.
+func (s *AvFormatContext) Repaint(r *image.Rectangle) error {
+	if r == nil {
+		return s.control(AV_APP_TO_DEV_WINDOW_REPAINT, nil, 0)
+	}
+	rect := deviceRect(*r)
+	return s.control(AV_APP_TO_DEV_WINDOW_REPAINT, unsafe.Pointer(&rect), unsafe.Sizeof(rect))
+}
+
+func deviceRect(r image.Rectangle) C.AVDeviceRect {
+	return C.AVDeviceRect{x: C.int(r.Min.X), y: C.int(r.Min.Y), width: C.int(r.Dx()), height: C.int(r.Dy())}
+}
+
+func (s *AvFormatContext) control(m AvAppToDevMessageType, data unsafe.Pointer, size uintptr) error {
+	ret := AvdeviceAppToDevControlMessage(s, m, data, size)
+	if ret == avutil.AVERROR_ENOSYS {
+		return ErrNotSupported
+	}
+	if ret < 0 {
+		return &avutil.Error{Num: ret}
+	}
+	return nil
+}
+
+type messageHandler struct {
+	handle    func(DeviceMessage) error
+	volume    float64
+	hasVolume bool
+	muted     bool
+	hasMuted  bool
+}
+
+var (
+	messageHandlers      = make(map[uintptr]*messageHandler)
+	messageHandlersMutex sync.Mutex
+)
+
+//Set the function the messages of the device are delivered to, such as buffer underflows or volume changes,
+//nil to stop delivering them as Close() does, which must be called before the context is freed.
+//The function may be called from a thread of the device and should return quickly, its error being returned to the device.
+//Devices rendering to a window, such as "opengl", leave the window to the application once a handler is set,
+//which must then handle the window buffer messages.
+func (s *AvFormatContext) SetMessageHandler(handle func(DeviceMessage) error) {
+	if handle == nil {
+		s.Close()
+		return
+	}
+	messageHandlersMutex.Lock()
+	defer messageHandlersMutex.Unlock()
+	s.messageHandler().handle = handle
+}
+
+//Stop delivering the messages of the device and drop the state it reported.
+//It must be called before the context is freed once SetMessageHandler(), NotifyMessages(), Volume() or Muted() were called.
+func (s *AvFormatContext) Close() {
+	messageHandlersMutex.Lock()
+	defer messageHandlersMutex.Unlock()
+	delete(messageHandlers, uintptr(unsafe.Pointer(s)))
+	C.goav_set_control_message_cb((*C.AVFormatContext)(s), 0)
+}
+
+//Return the entry of the context and install the callback, with the mutex held.
+//The entry is replaced when the context does not have the callback, the entry being left over
+//from a context freed at the same address without Close().
+func (s *AvFormatContext) messageHandler() *messageHandler {
+	key := uintptr(unsafe.Pointer(s))
+	h, ok := messageHandlers[key]
+	if !ok || C.goav_has_control_message_cb((*C.AVFormatContext)(s)) == 0 {
+		h = &messageHandler{}
+		messageHandlers[key] = h
+	}
+	C.goav_set_control_message_cb((*C.AVFormatContext)(s), 1)
+	return h
+}
+
+//Deliver the messages of the device to the channel c, as SetMessageHandler() does.
+//Messages are dropped when c is not ready to receive them, so that the device is never blocked.
+func (s *AvFormatContext) NotifyMessages(c chan<- DeviceMessage) {
+	s.SetMessageHandler(func(m DeviceMessage) error {
+		select {
+		case c <- m:
+		default:
+		}
+		return nil
+	})
+}
+
+//Send a GET message to the device and return whether it ever reported the state read by report.
+//The callback stays installed so that the state is kept, until Close() is called.
+func (s *AvFormatContext) query(m AvAppToDevMessageType, report func(h *messageHandler) bool) error {
+	messageHandlersMutex.Lock()
+	h := s.messageHandler()
+	messageHandlersMutex.Unlock()
+
+	err := s.control(m, nil, 0)
+
+	messageHandlersMutex.Lock()
+	defer messageHandlersMutex.Unlock()
+	reported := report(h)
+	if err != nil {
+		return err
+	}
+	if !reported {
+		return ErrNotSupported
+	}
+	return nil
+}
+
+func deviceMessage(t AvDevToAppMessageType, data unsafe.Pointer, size uintptr) DeviceMessage {
+	m := DeviceMessage{Type: t, Bytes: -1}
+	if data == nil {
+		return m
+	}
+	switch t {
+	case AV_DEV_TO_APP_CREATE_WINDOW_BUFFER:
+		if size >= C.sizeof_AVDeviceRect {
+			r := (*C.AVDeviceRect)(data)
+			window := image.Rect(int(r.x), int(r.y), int(r.x+r.width), int(r.y+r.height))
+			m.Window = &window
+		}
+	case AV_DEV_TO_APP_BUFFER_READABLE, AV_DEV_TO_APP_BUFFER_WRITABLE:
+		if size >= C.sizeof_int64_t {
+			m.Bytes = int64(*(*C.int64_t)(data))
+		}
+	case AV_DEV_TO_APP_MUTE_STATE_CHANGED:
+		if size >= C.sizeof_int {
+			m.Muted = *(*C.int)(data) != 0
+		}
+	case AV_DEV_TO_APP_VOLUME_LEVEL_CHANGED:
+		if size >= C.sizeof_double {
+			m.Volume = float64(*(*C.double)(data))
+		}
+	}
+	return m
+}
+
+func dispatchDeviceMessage(s unsafe.Pointer, t AvDevToAppMessageType, data unsafe.Pointer, size uintptr) int {
+	m := deviceMessage(t, data, size)
+	messageHandlersMutex.Lock()
+	h, ok := messageHandlers[uintptr(s)]
+	if !ok {
+		messageHandlersMutex.Unlock()
+		return avutil.AVERROR_ENOSYS
+	}
+	switch t {
+	case AV_DEV_TO_APP_MUTE_STATE_CHANGED:
+		h.muted, h.hasMuted = m.Muted, true
+	case AV_DEV_TO_APP_VOLUME_LEVEL_CHANGED:
+		h.volume, h.hasVolume = m.Volume, true
+	}
+	handle := h.handle
+	messageHandlersMutex.Unlock()
+	if handle == nil {
+		return 0
+	}
+	if err := handle(m); err != nil {
+		if e, ok := err.(*avutil.Error); ok {
+			return e.Num
+		}
+		return avutil.AVERROR_EINVAL
+	}
+	return 0
+}
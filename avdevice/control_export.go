@@ -0,0 +1,14 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avdevice
+
+//#cgo pkg-config: libavformat
+//#include <libavformat/avformat.h>
+import "C"
+import "unsafe"
+
+//export goavDeviceControlMessage
+func goavDeviceControlMessage(s *C.struct_AVFormatContext, t C.int, data unsafe.Pointer, size C.size_t) C.int {
+	return C.int(dispatchDeviceMessage(unsafe.Pointer(s), AvDevToAppMessageType(t), data, uintptr(size)))
+}
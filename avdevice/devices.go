@@ -0,0 +1,234 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avdevice
+
+/*
+#cgo pkg-config: libavdevice libavformat libavutil
+#include <libavdevice/avdevice.h>
+#include <libavformat/avformat.h>
+#include <libavutil/dict.h>
+#include <stdlib.h>
+
+#if LIBAVDEVICE_VERSION_INT >= AV_VERSION_INT(59, 0, 100)
+static inline int goav_device_info_media_types(AVDeviceInfo* d, enum AVMediaType** types)
+{
+	*types = d->media_types;
+	return d->nb_media_types;
+}
+#else
+static inline int goav_device_info_media_types(AVDeviceInfo* d, enum AVMediaType** types)
+{
+	*types = NULL;
+	return 0;
+}
+#endif
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avutil"
+)
+
+//ErrNotSupported is returned when a device does not support a request, such as listing its sources or a control message.
+var ErrNotSupported = &avutil.Error{Num: avutil.AVERROR_ENOSYS}
+
+func (l *AvDeviceInfoList) NbDevices() int {
+	return int(l.nb_devices)
+}
+
+//Return the index of the default device, -1 if there is none.
+func (l *AvDeviceInfoList) DefaultDevice() int {
+	return int(l.default_device)
+}
+
+func (l *AvDeviceInfoList) Devices() []*AvDeviceInfo {
+	if l.nb_devices <= 0 {
+		return nil
+	}
+	devices := (*[1 << 16]*AvDeviceInfo)(unsafe.Pointer(l.devices))[:l.nb_devices:l.nb_devices]
+	return append([]*AvDeviceInfo(nil), devices...)
+}
+
+//Return a copy of the devices with Go types.
+func (l *AvDeviceInfoList) Infos() []DeviceInfo {
+	var infos []DeviceInfo
+	for i, d := range l.Devices() {
+		infos = append(infos, DeviceInfo{
+			Name:        d.DeviceName(),
+			Description: d.DeviceDescription(),
+			Default:     i == l.DefaultDevice(),
+			MediaTypes:  d.MediaTypes(),
+		})
+	}
+	return infos
+}
+
+func (d *AvDeviceInfo) DeviceName() string {
+	return C.GoString(d.device_name)
+}
+
+func (d *AvDeviceInfo) DeviceDescription() string {
+	return C.GoString(d.device_description)
+}
+
+//Return the media types the device provides, always none before libavdevice 59.
+func (d *AvDeviceInfo) MediaTypes() []avutil.MediaType {
+	var types *C.enum_AVMediaType
+	n := int(C.goav_device_info_media_types((*C.AVDeviceInfo)(d), &types))
+	if n <= 0 || types == nil {
+		return nil
+	}
+	ctypes := (*[1 << 8]C.enum_AVMediaType)(unsafe.Pointer(types))[:n:n]
+	mediaTypes := make([]avutil.MediaType, n)
+	for i, t := range ctypes {
+		mediaTypes[i] = avutil.MediaType(t)
+	}
+	return mediaTypes
+}
+
+//DeviceInfo describes a source of an input device or a sink of an output device.
+type DeviceInfo struct {
+	//Name to open the device with, e.g. "/dev/video0" or "hw:1,0".
+	Name string
+	//Human readable description.
+	Description string
+	//Whether this is the default source or sink of the device.
+	Default bool
+	//Media types it provides, unknown before libavdevice 59.
+	MediaTypes []avutil.MediaType
+}
+
+func findInputDevice(format string) (*C.struct_AVInputFormat, error) {
+	C.avdevice_register_all()
+	cformat := C.CString(format)
+	defer C.free(unsafe.Pointer(cformat))
+	f := C.av_find_input_format(cformat)
+	if f == nil {
+		return nil, fmt.Errorf("Unknown input device %q", format)
+	}
+	return (*C.struct_AVInputFormat)(unsafe.Pointer(f)), nil
+}
+
+//List the sources of the input device of the given format, such as "v4l2", "alsa" or "pulse", configured with device options.
+//ErrNotSupported is returned when the device cannot list them, as "lavfi".
+func ListInputSources(format string, opts map[string]string) ([]DeviceInfo, error) {
+	f, err := findInputDevice(format)
+	if err != nil {
+		return nil, err
+	}
+	var d *avutil.Dictionary
+	for key, value := range opts {
+		avutil.AvDictSet(&d, key, value, 0)
+	}
+	defer avutil.AvDictFree(&d)
+	var list *C.struct_AVDeviceInfoList
+	ret := C.avdevice_list_input_sources(f, nil, (*C.struct_AVDictionary)(unsafe.Pointer(d)), &list)
+	return deviceInfos(list, ret)
+}
+
+//List the sinks of the output device of the given format, such as "alsa", "pulse" or "fbdev", configured with device options.
+//ErrNotSupported is returned when the device cannot list them.
+func ListOutputSinks(format string, opts map[string]string) ([]DeviceInfo, error) {
+	C.avdevice_register_all()
+	cformat := C.CString(format)
+	defer C.free(unsafe.Pointer(cformat))
+	f := C.av_guess_format(cformat, nil, nil)
+	if f == nil {
+		return nil, fmt.Errorf("Unknown output device %q", format)
+	}
+	var d *avutil.Dictionary
+	for key, value := range opts {
+		avutil.AvDictSet(&d, key, value, 0)
+	}
+	defer avutil.AvDictFree(&d)
+	var list *C.struct_AVDeviceInfoList
+	ret := C.avdevice_list_output_sinks((*C.struct_AVOutputFormat)(unsafe.Pointer(f)), nil, (*C.struct_AVDictionary)(unsafe.Pointer(d)), &list)
+	return deviceInfos(list, ret)
+}
+
+func deviceInfos(list *C.struct_AVDeviceInfoList, ret C.int) ([]DeviceInfo, error) {
+	defer C.avdevice_free_list_devices(&list)
+	if ret == C.int(avutil.AVERROR_ENOSYS) {
+		return nil, ErrNotSupported
+	}
+	if ret < 0 {
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	if list == nil {
+		return nil, nil
+	}
+	return (*AvDeviceInfoList)(list).Infos(), nil
+}
+
+//Size is the size of a video.
+type Size struct {
+	Width, Height int
+}
+
+//DeviceFormat lists the formats an input device captures with for the options it was opened with, one entry per stream.
+type DeviceFormat struct {
+	Codecs         []avcodec.CodecId
+	PixelFormats   []avcodec.PixelFormat
+	Sizes          []Size
+	FrameRates     []avutil.Rational
+	SampleFormats  []avcodec.AvSampleFormat
+	SampleRates    []int
+	ChannelLayouts []avutil.ChannelLayout
+}
+
+//Probe the format an input device, such as "v4l2" with "/dev/video0", or "lavfi" with "testsrc=size=640x480",
+//negotiates for device options such as {"video_size": "1280x720"}.
+//The device is opened and reports the formats of its streams for those options only,
+//probe again with other options, e.g. each size of interest, to find out about the others.
+//libavdevice once had a dedicated API enumerating the capabilities of devices, which no device implemented
+//and libavdevice 60 removed.
+func ProbeFormat(format, device string, opts map[string]string) (*DeviceFormat, error) {
+	f, err := findInputDevice(format)
+	if err != nil {
+		return nil, err
+	}
+	var d *avutil.Dictionary
+	for key, value := range opts {
+		avutil.AvDictSet(&d, key, value, 0)
+	}
+	defer avutil.AvDictFree(&d)
+	cdevice := C.CString(device)
+	defer C.free(unsafe.Pointer(cdevice))
+	var s *C.struct_AVFormatContext
+	if ret := C.avformat_open_input(&s, cdevice, f, (**C.struct_AVDictionary)(unsafe.Pointer(&d))); ret < 0 {
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	defer C.avformat_close_input(&s)
+	empty := C.CString("")
+	defer C.free(unsafe.Pointer(empty))
+	if e := C.av_dict_get((*C.struct_AVDictionary)(unsafe.Pointer(d)), empty, nil, C.AV_DICT_IGNORE_SUFFIX); e != nil {
+		return nil, fmt.Errorf("Unknown device option %q", C.GoString(e.key))
+	}
+	caps := &DeviceFormat{}
+	streams := (*[1 << 16]*C.struct_AVStream)(unsafe.Pointer(s.streams))[:s.nb_streams:s.nb_streams]
+	for _, st := range streams {
+		par := st.codecpar
+		caps.Codecs = append(caps.Codecs, avcodec.CodecId(par.codec_id))
+		switch par.codec_type {
+		case C.AVMEDIA_TYPE_VIDEO:
+			caps.PixelFormats = append(caps.PixelFormats, avcodec.PixelFormat(par.format))
+			caps.Sizes = append(caps.Sizes, Size{int(par.width), int(par.height)})
+			rate := st.avg_frame_rate
+			if rate.num <= 0 || rate.den <= 0 {
+				rate = st.r_frame_rate
+			}
+			if rate.num > 0 && rate.den > 0 {
+				caps.FrameRates = append(caps.FrameRates, avutil.NewRational(int(rate.num), int(rate.den)))
+			}
+		case C.AVMEDIA_TYPE_AUDIO:
+			caps.SampleFormats = append(caps.SampleFormats, avcodec.AvSampleFormat(par.format))
+			caps.SampleRates = append(caps.SampleRates, int(par.sample_rate))
+			caps.ChannelLayouts = append(caps.ChannelLayouts, (*avcodec.CodecParameters)(unsafe.Pointer(par)).ChLayout())
+		}
+	}
+	return caps, nil
+}
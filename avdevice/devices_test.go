@@ -0,0 +1,39 @@
+package avdevice
+
+import (
+	"testing"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+func TestListInputSourcesNotSupported(t *testing.T) {
+	if _, err := ListInputSources("lavfi", nil); err != ErrNotSupported {
+		t.Errorf("ListInputSources() of lavfi returned %#v, want ErrNotSupported", err)
+	}
+}
+
+func TestProbeFormat(t *testing.T) {
+	f, err := ProbeFormat("lavfi", "testsrc=size=320x240:rate=25", nil)
+	if err != nil {
+		t.Fatalf("ProbeFormat() failed: %#v", err)
+	}
+	if len(f.Sizes) != 1 || f.Sizes[0] != (Size{320, 240}) {
+		t.Errorf("Sizes are %v, want [{320 240}]", f.Sizes)
+	}
+	if len(f.FrameRates) != 1 || f.FrameRates[0].Cmp(avutil.NewRational(25, 1)) != 0 {
+		t.Errorf("Frame rates are %v, want [25/1]", f.FrameRates)
+	}
+	//testsrc draws in rgb24.
+	if len(f.PixelFormats) != 1 || f.PixelFormats[0].String() != "rgb24" {
+		t.Errorf("Pixel formats are %v, want [rgb24]", f.PixelFormats)
+	}
+	if len(f.SampleRates) != 0 {
+		t.Errorf("Sample rates are %v, want none", f.SampleRates)
+	}
+}
+
+func TestProbeFormatUnknownOption(t *testing.T) {
+	if _, err := ProbeFormat("lavfi", "testsrc", map[string]string{"no_such_option": "1"}); err == nil {
+		t.Error("ProbeFormat() with an unknown option succeeded")
+	}
+}
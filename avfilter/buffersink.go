@@ -0,0 +1,149 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avfilter
+
+/*
+#cgo pkg-config: libavfilter
+#include <libavfilter/avfilter.h>
+#include <libavfilter/buffersink.h>
+#include <libavfilter/version.h>
+#include <libavutil/channel_layout.h>
+#include <libavutil/mem.h>
+
+#if LIBAVFILTER_VERSION_INT >= AV_VERSION_INT(8, 44, 100)
+#define GOAV_HAS_CH_LAYOUT 1
+
+static inline void* goav_ch_layout_alloc(void)
+{
+	return av_mallocz(sizeof(AVChannelLayout));
+}
+
+static inline void goav_ch_layout_free(void* l)
+{
+	av_channel_layout_uninit(l);
+	av_free(l);
+}
+
+static inline int goav_buffersink_get_ch_layout(const AVFilterContext* ctx, void* l)
+{
+	return av_buffersink_get_ch_layout(ctx, l);
+}
+
+static inline uint64_t goav_buffersink_get_channel_layout(const AVFilterContext* ctx)
+{
+	return 0;
+}
+#else
+#define GOAV_HAS_CH_LAYOUT 0
+
+static inline void* goav_ch_layout_alloc(void)
+{
+	return NULL;
+}
+
+static inline void goav_ch_layout_free(void* l)
+{
+}
+
+static inline int goav_buffersink_get_ch_layout(const AVFilterContext* ctx, void* l)
+{
+	return AVERROR(ENOSYS);
+}
+
+static inline uint64_t goav_buffersink_get_channel_layout(const AVFilterContext* ctx)
+{
+	return av_buffersink_get_channel_layout(ctx);
+}
+#endif
+*/
+import "C"
+import (
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+const (
+	AV_BUFFERSINK_FLAG_PEEK       = int(C.AV_BUFFERSINK_FLAG_PEEK)
+	AV_BUFFERSINK_FLAG_NO_REQUEST = int(C.AV_BUFFERSINK_FLAG_NO_REQUEST)
+)
+
+//Get a frame with filtered data from sink and put it in frame.
+func (ctx *Context) AvBuffersinkGetFrame(f *avutil.Frame) int {
+	return int(C.av_buffersink_get_frame((*C.struct_AVFilterContext)(ctx), (*C.struct_AVFrame)(unsafe.Pointer(f))))
+}
+
+//Get a frame with filtered data from sink and put it in frame, with flags a combination of AV_BUFFERSINK_FLAG_*.
+func (ctx *Context) AvBuffersinkGetFrameFlags(f *avutil.Frame, fl int) int {
+	return int(C.av_buffersink_get_frame_flags((*C.struct_AVFilterContext)(ctx), (*C.struct_AVFrame)(unsafe.Pointer(f)), C.int(fl)))
+}
+
+//Same as AvBuffersinkGetFrame(), but with the ability to specify the number of samples read.
+func (ctx *Context) AvBuffersinkGetSamples(f *avutil.Frame, n int) int {
+	return int(C.av_buffersink_get_samples((*C.struct_AVFilterContext)(ctx), (*C.struct_AVFrame)(unsafe.Pointer(f)), C.int(n)))
+}
+
+//Set the frame size for an audio buffer sink.
+func (ctx *Context) AvBuffersinkSetFrameSize(s uint) {
+	C.av_buffersink_set_frame_size((*C.struct_AVFilterContext)(ctx), C.uint(s))
+}
+
+func (ctx *Context) AvBuffersinkGetType() MediaType {
+	return (MediaType)(C.av_buffersink_get_type((*C.struct_AVFilterContext)(ctx)))
+}
+
+func (ctx *Context) AvBuffersinkGetTimeBase() avutil.Rational {
+	r := C.av_buffersink_get_time_base((*C.struct_AVFilterContext)(ctx))
+	return *((*avutil.Rational)(unsafe.Pointer(&r)))
+}
+
+func (ctx *Context) AvBuffersinkGetFormat() int {
+	return int(C.av_buffersink_get_format((*C.struct_AVFilterContext)(ctx)))
+}
+
+func (ctx *Context) AvBuffersinkGetFrameRate() avutil.Rational {
+	r := C.av_buffersink_get_frame_rate((*C.struct_AVFilterContext)(ctx))
+	return *((*avutil.Rational)(unsafe.Pointer(&r)))
+}
+
+func (ctx *Context) AvBuffersinkGetW() int {
+	return int(C.av_buffersink_get_w((*C.struct_AVFilterContext)(ctx)))
+}
+
+func (ctx *Context) AvBuffersinkGetH() int {
+	return int(C.av_buffersink_get_h((*C.struct_AVFilterContext)(ctx)))
+}
+
+func (ctx *Context) AvBuffersinkGetSampleAspectRatio() avutil.Rational {
+	r := C.av_buffersink_get_sample_aspect_ratio((*C.struct_AVFilterContext)(ctx))
+	return *((*avutil.Rational)(unsafe.Pointer(&r)))
+}
+
+func (ctx *Context) AvBuffersinkGetChannels() int {
+	return int(C.av_buffersink_get_channels((*C.struct_AVFilterContext)(ctx)))
+}
+
+//Return the channel layout of the sink, read with av_buffersink_get_ch_layout() or av_buffersink_get_channel_layout() depending on the libavfilter version.
+func (ctx *Context) AvBuffersinkGetChLayout() avutil.ChannelLayout {
+	cctx := (*C.struct_AVFilterContext)(ctx)
+	if C.GOAV_HAS_CH_LAYOUT == 0 {
+		if mask := uint64(C.goav_buffersink_get_channel_layout(cctx)); mask != 0 {
+			return avutil.ChannelLayoutFromMask(mask)
+		}
+		return avutil.ChannelLayout{NbChannels: ctx.AvBuffersinkGetChannels()}
+	}
+	l := C.goav_ch_layout_alloc()
+	if l == nil {
+		return avutil.ChannelLayout{}
+	}
+	defer C.goav_ch_layout_free(l)
+	if C.goav_buffersink_get_ch_layout(cctx, l) < 0 {
+		return avutil.ChannelLayout{}
+	}
+	return avutil.ChannelLayoutAt(l)
+}
+
+func (ctx *Context) AvBuffersinkGetSampleRate() int {
+	return int(C.av_buffersink_get_sample_rate((*C.struct_AVFilterContext)(ctx)))
+}
@@ -0,0 +1,136 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avfilter
+
+/*
+#cgo pkg-config: libavfilter
+#include <libavfilter/avfilter.h>
+#include <libavfilter/buffersrc.h>
+#include <libavfilter/version.h>
+#include <libavutil/channel_layout.h>
+#include <libavutil/mem.h>
+
+#if LIBAVFILTER_VERSION_INT >= AV_VERSION_INT(8, 44, 100)
+#define GOAV_HAS_CH_LAYOUT 1
+
+static inline void* goav_buffersrc_par_ch_layout(AVBufferSrcParameters* p)
+{
+	return &p->ch_layout;
+}
+
+static inline void goav_buffersrc_par_set_channel_layout(AVBufferSrcParameters* p, uint64_t mask)
+{
+}
+
+static inline void goav_buffersrc_par_free(AVBufferSrcParameters* p)
+{
+	av_channel_layout_uninit(&p->ch_layout);
+	av_free(p);
+}
+#else
+#define GOAV_HAS_CH_LAYOUT 0
+
+static inline void* goav_buffersrc_par_ch_layout(AVBufferSrcParameters* p)
+{
+	return NULL;
+}
+
+static inline void goav_buffersrc_par_set_channel_layout(AVBufferSrcParameters* p, uint64_t mask)
+{
+	p->channel_layout = mask;
+}
+
+static inline void goav_buffersrc_par_free(AVBufferSrcParameters* p)
+{
+	av_free(p);
+}
+#endif
+*/
+import "C"
+import (
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+type BuffersrcParameters C.struct_AVBufferSrcParameters
+
+const (
+	AV_BUFFERSRC_FLAG_NO_CHECK_FORMAT = int(C.AV_BUFFERSRC_FLAG_NO_CHECK_FORMAT)
+	AV_BUFFERSRC_FLAG_PUSH            = int(C.AV_BUFFERSRC_FLAG_PUSH)
+	AV_BUFFERSRC_FLAG_KEEP_REF        = int(C.AV_BUFFERSRC_FLAG_KEEP_REF)
+)
+
+//Allocate a new BuffersrcParameters instance. It should be freed by the caller with AvFree().
+func AvBuffersrcParametersAlloc() *BuffersrcParameters {
+	return (*BuffersrcParameters)(C.av_buffersrc_parameters_alloc())
+}
+
+//Free a BuffersrcParameters instance allocated with AvBuffersrcParametersAlloc().
+func (p *BuffersrcParameters) AvFree() {
+	C.goav_buffersrc_par_free((*C.struct_AVBufferSrcParameters)(p))
+}
+
+func (p *BuffersrcParameters) SetFormat(format int) {
+	p.format = C.int(format)
+}
+
+func (p *BuffersrcParameters) SetTimeBase(timeBase avutil.Rational) {
+	p.time_base = *((*C.struct_AVRational)(unsafe.Pointer(&timeBase)))
+}
+
+func (p *BuffersrcParameters) SetWidth(width int) {
+	p.width = C.int(width)
+}
+
+func (p *BuffersrcParameters) SetHeight(height int) {
+	p.height = C.int(height)
+}
+
+func (p *BuffersrcParameters) SetSampleAspectRatio(sar avutil.Rational) {
+	p.sample_aspect_ratio = *((*C.struct_AVRational)(unsafe.Pointer(&sar)))
+}
+
+func (p *BuffersrcParameters) SetFrameRate(frameRate avutil.Rational) {
+	p.frame_rate = *((*C.struct_AVRational)(unsafe.Pointer(&frameRate)))
+}
+
+func (p *BuffersrcParameters) SetSampleRate(sampleRate int) {
+	p.sample_rate = C.int(sampleRate)
+}
+
+//Set the channel layout, stored in ch_layout or in the legacy channel_layout depending on the libavfilter version.
+func (p *BuffersrcParameters) SetChLayout(l avutil.ChannelLayout) error {
+	if C.GOAV_HAS_CH_LAYOUT == 0 {
+		C.goav_buffersrc_par_set_channel_layout((*C.struct_AVBufferSrcParameters)(p), C.uint64_t(l.LegacyMask()))
+		return nil
+	}
+	return l.CopyTo(C.goav_buffersrc_par_ch_layout((*C.struct_AVBufferSrcParameters)(p)))
+}
+
+//Initialize the buffersrc or abuffersrc filter with the provided parameters.
+func (ctx *Context) AvBuffersrcParametersSet(p *BuffersrcParameters) int {
+	return int(C.av_buffersrc_parameters_set((*C.struct_AVFilterContext)(ctx), (*C.struct_AVBufferSrcParameters)(p)))
+}
+
+//Get the number of failed requests.
+func (ctx *Context) AvBuffersrcGetNbFailedRequests() uint {
+	return uint(C.av_buffersrc_get_nb_failed_requests((*C.struct_AVFilterContext)(ctx)))
+}
+
+//Add a frame to the buffer source, taking ownership of its reference. A nil frame marks the end of the stream.
+func (ctx *Context) AvBuffersrcAddFrame(f *avutil.Frame) int {
+	return int(C.av_buffersrc_add_frame((*C.struct_AVFilterContext)(ctx), (*C.struct_AVFrame)(unsafe.Pointer(f))))
+}
+
+//Add a frame to the buffer source, with flags a combination of AV_BUFFERSRC_FLAG_*.
+//Unless AV_BUFFERSRC_FLAG_KEEP_REF is set the reference of the frame is taken over. A nil frame marks the end of the stream.
+func (ctx *Context) AvBuffersrcAddFrameFlags(f *avutil.Frame, fl int) int {
+	return int(C.av_buffersrc_add_frame_flags((*C.struct_AVFilterContext)(ctx), (*C.struct_AVFrame)(unsafe.Pointer(f)), C.int(fl)))
+}
+
+//Close the buffer source after EOF, pts being the timestamp of the end of the stream.
+func (ctx *Context) AvBuffersrcClose(pts int64, fl uint) int {
+	return int(C.av_buffersrc_close((*C.struct_AVFilterContext)(ctx), C.int64_t(pts), C.uint(fl)))
+}
@@ -0,0 +1,448 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avfilter
+
+/*
+#cgo pkg-config: libavfilter
+#include <libavfilter/avfilter.h>
+#include <libavutil/frame.h>
+#include <libavutil/pixdesc.h>
+#include <string.h>
+*/
+import "C"
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avformat"
+	"github.com/alon-ne/goav/avutil"
+)
+
+//BurnInOptions configures a SubtitleBurner.
+type BurnInOptions struct {
+	//Directory searched for the fonts of text subtitles, in addition to the system ones.
+	FontsDir string
+	//ASS style fields overriding those of text subtitles, by name, e.g. {"FontName": "DejaVu Sans", "FontSize": "24", "Outline": "2"}.
+	ForceStyle map[string]string
+	//ASS script header with the styles of text subtitles, such as SubtitleDecoder.Header(), avcodec.DefaultASSHeader when empty.
+	Header string
+	//Size of the video the positions of bitmap subtitles refer to, that of the frames when zero.
+	//The bitmaps are scaled to the frames when it differs.
+	Width, Height int
+}
+
+//SubtitleBurner draws subtitles onto video frames, e.g. to burn captions into the picture.
+//Text subtitles are rendered by the subtitles filter, which requires libavfilter to be built with libass.
+//Bitmap subtitles, such as DVB, DVD or PGS ones, are drawn onto a transparent canvas overlaid onto the frames.
+//A subtitle is shown on the frames whose timestamp falls between its start and its end,
+//or the start of the next subtitle when its end is unknown.
+type SubtitleBurner struct {
+	fg       *FilterGraph
+	video    InputSpec
+	bitmaps  []timedSubtitle
+	canvas   *C.struct_AVFrame
+	active   []int
+	width    int
+	height   int
+	script   string
+	finished bool
+}
+
+//timedSubtitle is a subtitle with its display interval in AV_TIME_BASE units.
+type timedSubtitle struct {
+	*avcodec.Subtitle
+	start, end int64
+}
+
+//Return a copy of the subtitle with only the given rects.
+func (s timedSubtitle) withRects(rects []avcodec.SubtitleRect) timedSubtitle {
+	sub := *s.Subtitle
+	sub.Rects = rects
+	s.Subtitle = &sub
+	return s
+}
+
+//Create a SubtitleBurner for frames of the given spec, drawing the given subtitles, whose timestamps are on the timeline of the frames.
+func NewSubtitleBurner(video InputSpec, subs []*avcodec.Subtitle, opts BurnInOptions) (*SubtitleBurner, error) {
+	if video.isAudio() {
+		return nil, fmt.Errorf("Subtitles can only be burned into video")
+	}
+	b := &SubtitleBurner{video: video, width: opts.Width, height: opts.Height}
+	if b.width <= 0 || b.height <= 0 {
+		b.width, b.height = video.Width, video.Height
+	}
+	var texts []timedSubtitle
+	for _, s := range timeSubtitles(subs) {
+		//The bitmap and the text rects of a subtitle are drawn by different filters.
+		var bitmapRects, textRects []avcodec.SubtitleRect
+		for _, r := range s.Rects {
+			if r.Type == avcodec.SUBTITLE_BITMAP {
+				bitmapRects = append(bitmapRects, r)
+			} else {
+				textRects = append(textRects, r)
+			}
+		}
+		if len(bitmapRects) > 0 {
+			b.bitmaps = append(b.bitmaps, s.withRects(bitmapRects))
+		}
+		if len(textRects) > 0 {
+			texts = append(texts, s.withRects(textRects))
+		}
+	}
+	if err := b.build(texts, opts); err != nil {
+		b.Free()
+		return nil, err
+	}
+	return b, nil
+}
+
+//Create a SubtitleBurner for frames of the given spec, drawing the subtitles of a stream of input.
+//The input is read through for the subtitles and then seeked back to its start, so it must be seekable, such as a file.
+//For other inputs, decode the subtitles with a SubtitleDecoder and give them to NewSubtitleBurner().
+//The positions of bitmap subtitles refer to the size of the subtitle stream when known and not set in opts.
+func NewSubtitleBurnerFromStream(video InputSpec, input *avformat.Context, streamIndex int, opts BurnInOptions) (*SubtitleBurner, error) {
+	subs, header, par, err := readSubtitles(input, streamIndex)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Header == "" {
+		opts.Header = header
+	}
+	if opts.Width <= 0 || opts.Height <= 0 {
+		opts.Width, opts.Height = par.Width(), par.Height()
+	}
+	return NewSubtitleBurner(video, subs, opts)
+}
+
+//Decode all the subtitles of a stream and seek the input back to its start.
+func readSubtitles(input *avformat.Context, streamIndex int) ([]*avcodec.Subtitle, string, *avcodec.CodecParameters, error) {
+	streams := input.Streams()
+	if streamIndex < 0 || streamIndex >= len(streams) {
+		return nil, "", nil, fmt.Errorf("Invalid stream index %d", streamIndex)
+	}
+	st := streams[streamIndex]
+	par := st.CodecPar()
+	if par.CodecType() != avutil.AVMEDIA_TYPE_SUBTITLE {
+		return nil, "", nil, fmt.Errorf("Stream %d is not a subtitle stream", streamIndex)
+	}
+	dec, err := avcodec.NewSubtitleDecoder(par, st.TimeBase())
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer dec.Free()
+	var subs []*avcodec.Subtitle
+	for {
+		p := avcodec.AvPacketAlloc()
+		if ret := input.AvReadFrame(p); ret < 0 {
+			avcodec.AvPacketFree(p)
+			if ret == avutil.AVERROR_EOF {
+				break
+			}
+			return nil, "", nil, &avutil.Error{Num: ret}
+		}
+		var s *avcodec.Subtitle
+		if p.StreamIndex() == streamIndex {
+			s, err = dec.Decode(p)
+		}
+		avcodec.AvPacketFree(p)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if s != nil {
+			subs = append(subs, s)
+		}
+	}
+	s, err := dec.Flush()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if s != nil {
+		subs = append(subs, s)
+	}
+	if ret := input.AvformatSeekFile(-1, math.MinInt64, 0, math.MaxInt64, 0); ret < 0 {
+		return nil, "", nil, &avutil.Error{Num: ret}
+	}
+	return subs, dec.Header(), par, nil
+}
+
+//Sort the subtitles with a timestamp by start and compute their end.
+func timeSubtitles(subs []*avcodec.Subtitle) []timedSubtitle {
+	var timed []timedSubtitle
+	for _, s := range subs {
+		if s != nil && s.Pts != avutil.AV_NOPTS_VALUE {
+			timed = append(timed, timedSubtitle{Subtitle: s, start: s.Start(), end: s.End()})
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].start < timed[j].start })
+	//libavcodec uses UINT32_MAX for an unknown end display time.
+	const unknown = time.Duration(math.MaxUint32) * time.Millisecond
+	for i := range timed {
+		if timed[i].EndDisplayTime <= timed[i].StartDisplayTime || timed[i].EndDisplayTime >= unknown {
+			timed[i].end = math.MaxInt64
+			if i+1 < len(timed) {
+				timed[i].end = timed[i+1].start
+			}
+		}
+	}
+	return timed
+}
+
+func (b *SubtitleBurner) build(texts []timedSubtitle, opts BurnInOptions) error {
+	main := "[in]"
+	var chains []string
+	if len(texts) > 0 {
+		if AvfilterGetByName("subtitles") == nil {
+			return fmt.Errorf("Burning text subtitles needs the subtitles filter, libavfilter must be built with libass")
+		}
+		if err := b.writeScript(texts, opts.Header); err != nil {
+			return err
+		}
+		args := []string{"filename=" + escapeFilterArg(b.script)}
+		if opts.FontsDir != "" {
+			args = append(args, "fontsdir="+escapeFilterArg(opts.FontsDir))
+		}
+		if len(opts.ForceStyle) > 0 {
+			var style []string
+			for name, value := range opts.ForceStyle {
+				style = append(style, name+"="+value)
+			}
+			sort.Strings(style)
+			args = append(args, "force_style="+escapeFilterArg(strings.Join(style, ",")))
+		}
+		chains = append(chains, "[in]subtitles="+strings.Join(args, ":")+"[text]")
+		main = "[text]"
+	}
+	inputs := map[string]InputSpec{"in": b.video}
+	if len(b.bitmaps) > 0 {
+		inputs["sub"] = InputSpec{
+			Format:            int(C.AV_PIX_FMT_RGBA),
+			TimeBase:          b.video.TimeBase,
+			Width:             b.width,
+			Height:            b.height,
+			SampleAspectRatio: avutil.NewRational(1, 1),
+		}
+		sub := "[sub]"
+		if b.width != b.video.Width || b.height != b.video.Height {
+			chains = append(chains, fmt.Sprintf("[sub]scale=%d:%d[scaled]", b.video.Width, b.video.Height))
+			sub = "[scaled]"
+		}
+		//overlay converts the frames to its own format, they are converted back to theirs.
+		chains = append(chains, main+sub+"overlay=format=auto:eof_action=pass,format=pix_fmts="+
+			C.GoString(C.av_get_pix_fmt_name(C.enum_AVPixelFormat(b.video.Format)))+"[out]")
+	} else if len(chains) > 0 {
+		chains[0] = strings.TrimSuffix(chains[0], "[text]") + "[out]"
+	} else {
+		chains = append(chains, "[in]null[out]")
+	}
+	fg, err := NewFilterGraph(strings.Join(chains, ";"), inputs)
+	if err != nil {
+		return err
+	}
+	b.fg = fg
+	return nil
+}
+
+//Write the text subtitles into an ASS script for the subtitles filter.
+func (b *SubtitleBurner) writeScript(texts []timedSubtitle, header string) error {
+	if header == "" {
+		header = avcodec.DefaultASSHeader
+	}
+	f, err := os.CreateTemp("", "goav-*.ass")
+	if err != nil {
+		return err
+	}
+	b.script = f.Name()
+	var sb strings.Builder
+	sb.WriteString(header)
+	for _, s := range texts {
+		for _, r := range s.Rects {
+			var line string
+			switch r.Type {
+			case avcodec.SUBTITLE_ASS:
+				line = assDialogue(r.Ass, s.start, s.end)
+			case avcodec.SUBTITLE_TEXT:
+				text := strings.NewReplacer("\r\n", "\\N", "\n", "\\N", "{", "\\{", "}", "\\}").Replace(r.Text)
+				line = assDialogue("0,0,Default,,0,0,0,,"+text, s.start, s.end)
+			}
+			if line != "" {
+				sb.WriteString(line + "\r\n")
+			}
+		}
+	}
+	_, err = f.WriteString(sb.String())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+//Turn the "ReadOrder,Layer,Style,Name,MarginL,MarginR,MarginV,Effect,Text" line of a decoder into a Dialogue event of a script.
+func assDialogue(line string, start, end int64) string {
+	if strings.HasPrefix(line, "Dialogue:") {
+		return line
+	}
+	fields := strings.SplitN(line, ",", 3)
+	if len(fields) < 3 {
+		return ""
+	}
+	return fmt.Sprintf("Dialogue: %s,%s,%s,%s", fields[1], assTime(start), assTime(end), fields[2])
+}
+
+//Format a time in AV_TIME_BASE units as an ASS time, H:MM:SS.cc.
+func assTime(t int64) string {
+	if t < 0 {
+		t = 0
+	}
+	cs := t / 10000
+	if t == math.MaxInt64 || cs >= 10*3600*100 {
+		cs = 10*3600*100 - 1
+	}
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
+
+//Escape a value for a filter option within a filtergraph description.
+func escapeFilterArg(v string) string {
+	escape := func(s, special string) string {
+		var sb strings.Builder
+		for _, c := range s {
+			if strings.ContainsRune(special, c) {
+				sb.WriteByte('\\')
+			}
+			sb.WriteRune(c)
+		}
+		return sb.String()
+	}
+	return escape(escape(v, `\':`), `\'[],;`)
+}
+
+//Push a video frame, whose subtitles are drawn onto it. The frame is referenced, the caller keeps ownership of it.
+//The subtitles shown are picked by the timestamp of the frame, which must be set.
+//A nil frame marks the end of the video, Pull() then drains the burner and returns io.EOF.
+func (b *SubtitleBurner) Push(f *avutil.Frame) error {
+	if f != nil && f.Pts() == avutil.AV_NOPTS_VALUE {
+		return fmt.Errorf("Cannot burn subtitles into a frame without a timestamp")
+	}
+	if len(b.bitmaps) > 0 {
+		if err := b.pushCanvas(f); err != nil {
+			return err
+		}
+	}
+	return b.fg.Push("in", f)
+}
+
+//Push the canvas with the bitmap subtitles shown at the time of f, with its timestamp.
+func (b *SubtitleBurner) pushCanvas(f *avutil.Frame) error {
+	if f == nil {
+		if b.finished {
+			return nil
+		}
+		b.finished = true
+		return b.fg.Push("sub", nil)
+	}
+	t := avutil.AvRescaleQ(f.Pts(), b.video.TimeBase, avutil.AvGetTimeBaseQ())
+	var active []int
+	for i, s := range b.bitmaps {
+		if s.start <= t && t < s.end {
+			active = append(active, i)
+		}
+	}
+	if b.canvas == nil || !equalInts(active, b.active) {
+		if err := b.render(active); err != nil {
+			return err
+		}
+	}
+	b.canvas.pts = C.int64_t(f.Pts())
+	return b.fg.Push("sub", (*avutil.Frame)(unsafe.Pointer(b.canvas)))
+}
+
+//Draw the bitmaps of the given subtitles onto a new transparent canvas.
+func (b *SubtitleBurner) render(active []int) error {
+	canvas := C.av_frame_alloc()
+	if canvas == nil {
+		return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	canvas.format = C.AV_PIX_FMT_RGBA
+	canvas.width, canvas.height = C.int(b.width), C.int(b.height)
+	if ret := C.av_frame_get_buffer(canvas, 0); ret < 0 {
+		C.av_frame_free(&canvas)
+		return &avutil.Error{Num: int(ret)}
+	}
+	stride := int(canvas.linesize[0])
+	pix := (*[1 << 30]byte)(unsafe.Pointer(canvas.data[0]))[: stride*b.height : stride*b.height]
+	for i := range pix {
+		pix[i] = 0
+	}
+	for _, i := range active {
+		for _, r := range b.bitmaps[i].Rects {
+			if r.Image == nil {
+				continue
+			}
+			var palette [256][4]byte
+			for j, c := range r.Image.Palette {
+				n := color.NRGBAModel.Convert(c).(color.NRGBA)
+				palette[j] = [4]byte{n.R, n.G, n.B, n.A}
+			}
+			bounds := r.Image.Bounds()
+			for y := 0; y < bounds.Dy(); y++ {
+				cy := r.Y + y
+				if cy < 0 || cy >= b.height {
+					continue
+				}
+				for x := 0; x < bounds.Dx(); x++ {
+					cx := r.X + x
+					if cx < 0 || cx >= b.width {
+						continue
+					}
+					c := palette[r.Image.ColorIndexAt(bounds.Min.X+x, bounds.Min.Y+y)]
+					if c[3] != 0 {
+						copy(pix[cy*stride+cx*4:], c[:])
+					}
+				}
+			}
+		}
+	}
+	C.av_frame_free(&b.canvas)
+	b.canvas, b.active = canvas, active
+	return nil
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//Pull the next frame with its subtitles drawn, owned by the caller.
+//ErrAgain is returned when more frames must be pushed, io.EOF once the end of the video was pushed and all the frames pulled.
+func (b *SubtitleBurner) Pull() (*avutil.Frame, error) {
+	return b.fg.Pull("out")
+}
+
+//Return the underlying FilterGraph.
+func (b *SubtitleBurner) FilterGraph() *FilterGraph {
+	return b.fg
+}
+
+func (b *SubtitleBurner) Free() {
+	if b.fg != nil {
+		b.fg.Free()
+	}
+	C.av_frame_free(&b.canvas)
+	if b.script != "" {
+		os.Remove(b.script)
+	}
+}
@@ -0,0 +1,123 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avfilter
+
+/*
+#cgo pkg-config: libavfilter
+#include <libavfilter/avfilter.h>
+#include <libavfilter/version.h>
+#include <stdlib.h>
+
+static inline const AVFilter* goav_filter_iterate(void** opaque)
+{
+#if LIBAVFILTER_VERSION_INT >= AV_VERSION_INT(7, 14, 100)
+	return av_filter_iterate(opaque);
+#else
+	const AVFilter* f = avfilter_next(*opaque);
+	*opaque = (void*)f;
+	return f;
+#endif
+}
+
+static inline int goav_filter_pad_count(const AVFilter* f, int is_output)
+{
+#if LIBAVFILTER_VERSION_INT >= AV_VERSION_INT(8, 24, 100)
+	return avfilter_filter_pad_count(f, is_output);
+#else
+	return avfilter_pad_count(is_output ? f->outputs : f->inputs);
+#endif
+}
+
+static inline int goav_filter_has_commands(const AVFilter* f)
+{
+	return f->process_command != NULL;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+const (
+	AVFILTER_FLAG_DYNAMIC_INPUTS            = int(C.AVFILTER_FLAG_DYNAMIC_INPUTS)
+	AVFILTER_FLAG_DYNAMIC_OUTPUTS           = int(C.AVFILTER_FLAG_DYNAMIC_OUTPUTS)
+	AVFILTER_FLAG_SLICE_THREADS             = int(C.AVFILTER_FLAG_SLICE_THREADS)
+	AVFILTER_FLAG_SUPPORT_TIMELINE_GENERIC  = int(C.AVFILTER_FLAG_SUPPORT_TIMELINE_GENERIC)
+	AVFILTER_FLAG_SUPPORT_TIMELINE_INTERNAL = int(C.AVFILTER_FLAG_SUPPORT_TIMELINE_INTERNAL)
+	AVFILTER_FLAG_SUPPORT_TIMELINE          = AVFILTER_FLAG_SUPPORT_TIMELINE_GENERIC | AVFILTER_FLAG_SUPPORT_TIMELINE_INTERNAL
+)
+
+//FilterInfo describes a filter available in libavfilter.
+//The pads are those the filter always has, a filter with dynamic inputs or outputs adds more depending on its options.
+type FilterInfo struct {
+	Name           string          `json:"name"`
+	Description    string          `json:"description"`
+	Inputs         []PadInfo       `json:"inputs"`
+	Outputs        []PadInfo       `json:"outputs"`
+	Flags          int             `json:"flags"`
+	DynamicInputs  bool            `json:"dynamic_inputs"`
+	DynamicOutputs bool            `json:"dynamic_outputs"`
+	Timeline       bool            `json:"timeline"`
+	SliceThreads   bool            `json:"slice_threads"`
+	Commands       bool            `json:"commands"`
+	Options        []avutil.Option `json:"options"`
+}
+
+//Return all the filters available in libavfilter.
+func Filters() []FilterInfo {
+	var filters []FilterInfo
+	var opaque unsafe.Pointer
+	for f := C.goav_filter_iterate(&opaque); f != nil; f = C.goav_filter_iterate(&opaque) {
+		filters = append(filters, (*Filter)(unsafe.Pointer(f)).Info())
+	}
+	return filters
+}
+
+//Return the description of the filter with the given name.
+func LookupFilter(name string) (FilterInfo, error) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	f := C.avfilter_get_by_name(cname)
+	if f == nil {
+		return FilterInfo{}, fmt.Errorf("Filter %q not found", name)
+	}
+	return (*Filter)(unsafe.Pointer(f)).Info(), nil
+}
+
+//Describe the filter.
+func (f *Filter) Info() FilterInfo {
+	cf := (*C.struct_AVFilter)(f)
+	flags := int(cf.flags)
+	info := FilterInfo{
+		Name:           C.GoString(cf.name),
+		Description:    C.GoString(cf.description),
+		Flags:          flags,
+		DynamicInputs:  flags&AVFILTER_FLAG_DYNAMIC_INPUTS != 0,
+		DynamicOutputs: flags&AVFILTER_FLAG_DYNAMIC_OUTPUTS != 0,
+		Timeline:       flags&AVFILTER_FLAG_SUPPORT_TIMELINE != 0,
+		SliceThreads:   flags&AVFILTER_FLAG_SLICE_THREADS != 0,
+		Commands:       C.goav_filter_has_commands(cf) != 0,
+		Options:        avutil.ClassOptions(unsafe.Pointer(cf.priv_class)),
+	}
+	for i := 0; i < int(C.goav_filter_pad_count(cf, 0)); i++ {
+		info.Inputs = append(info.Inputs, padInfo(cf.inputs, i))
+	}
+	for i := 0; i < int(C.goav_filter_pad_count(cf, 1)); i++ {
+		info.Outputs = append(info.Outputs, padInfo(cf.outputs, i))
+	}
+	return info
+}
+
+//Return the option with the given name, aliases such as "w" for "width" are listed as options of their own.
+func (info FilterInfo) Option(name string) (avutil.Option, bool) {
+	for _, o := range info.Options {
+		if o.Name == name {
+			return o, true
+		}
+	}
+	return avutil.Option{}, false
+}
@@ -0,0 +1,124 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avfilter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+//Send a command to the filters matching target, which is an instance name such as "volume@music", a filter name or "all".
+//The command is applied immediately and the responses of the filters are returned.
+func (fg *FilterGraph) SendCommand(target, cmd, arg string) (string, error) {
+	var responses []string
+	handled := false
+	for _, seg := range fg.segments {
+		res, err := (*Graph)(seg.graph).SendCommand(target, cmd, arg, 0)
+		if e, ok := err.(*avutil.Error); ok && e.Num == avutil.AVERROR_ENOSYS {
+			continue
+		}
+		if res != "" {
+			responses = append(responses, res)
+		}
+		if err != nil {
+			return strings.Join(responses, "\n"), err
+		}
+		handled = true
+	}
+	if !handled {
+		return "", &avutil.Error{Num: avutil.AVERROR_ENOSYS}
+	}
+	return strings.Join(responses, "\n"), nil
+}
+
+//Queue a command for the filters matching target, executed once the frames reaching them pass ts expressed in time base tb.
+func (fg *FilterGraph) QueueCommand(target, cmd, arg string, ts int64, tb avutil.Rational) error {
+	return fg.QueueCommandAt(target, cmd, arg, tb.Duration(ts))
+}
+
+//Queue a command for the filters matching target, executed once the frames reaching them pass the stream position at.
+func (fg *FilterGraph) QueueCommandAt(target, cmd, arg string, at time.Duration) error {
+	for _, seg := range fg.segments {
+		if err := (*Graph)(seg.graph).QueueCommand(target, cmd, arg, 0, at.Seconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Replace the graph by one built from a new description, with the same inputs and the same outputs.
+//The current graph is flushed first and the frames it still held are returned by Pull() before those of the new graph,
+//so no frame pushed before the call is lost.
+//The current graph is left untouched when the new description is invalid or has other outputs,
+//but its inputs are closed once flushing starts, so that it no longer accepts frames should flushing fail.
+func (fg *FilterGraph) Reconfigure(desc string) error {
+	next, err := NewFilterGraph(desc, fg.specs)
+	if err != nil {
+		return err
+	}
+	if got, want := next.Outputs(), fg.Outputs(); !sameNames(got, want) {
+		next.Free()
+		return fmt.Errorf("Reconfigured filter graph outputs %q differ from %q", got, want)
+	}
+	if err := fg.flush(); err != nil {
+		next.Free()
+		return err
+	}
+	for _, seg := range fg.segments {
+		seg.free()
+	}
+	fg.segments, fg.procs, fg.eof = next.segments, next.procs, next.eof
+	return nil
+}
+
+//Return whether a and b hold the same names, in any order.
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	names := make(map[string]bool, len(a))
+	for _, name := range a {
+		names[name] = true
+	}
+	for _, name := range b {
+		if !names[name] {
+			return false
+		}
+	}
+	return true
+}
+
+//Close all inputs and move the frames left in the graph to the pending queue of their output.
+func (fg *FilterGraph) flush() error {
+	first := fg.first()
+	for _, name := range first.inputNames {
+		if ret := first.inputs[name].AvBuffersrcAddFrameFlags(nil, 0); ret < 0 && ret != avutil.AVERROR_EOF {
+			return &avutil.Error{Num: ret}
+		}
+	}
+	if err := fg.pump(); err != nil {
+		return err
+	}
+	if fg.pending == nil {
+		fg.pending = map[string][]*avutil.Frame{}
+	}
+	last := fg.last()
+	for _, name := range last.outputNames {
+		for {
+			f, err := last.pull(name)
+			if err == io.EOF || err == ErrAgain {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			fg.pending[name] = append(fg.pending[name], f)
+		}
+	}
+	return nil
+}
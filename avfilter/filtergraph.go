@@ -0,0 +1,438 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avfilter
+
+/*
+#cgo pkg-config: libavfilter
+#include <libavfilter/avfilter.h>
+#include <libavutil/frame.h>
+#include <stdlib.h>
+
+static inline int goav_inout_pad_type(const AVFilterInOut* io, int input)
+{
+	const AVFilterContext* f = io->filter_ctx;
+	return avfilter_pad_get_type(input ? f->input_pads : f->output_pads, io->pad_idx);
+}
+*/
+import "C"
+import (
+	"fmt"
+	"io"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+//ErrAgain is returned by FilterGraph.Pull() when more input is needed before an output frame is available.
+var ErrAgain = &avutil.Error{Num: avutil.AVERROR_EAGAIN}
+
+//InputSpec describes the frames pushed into one input of a FilterGraph.
+//Video inputs use Width, Height, SampleAspectRatio and FrameRate, audio inputs SampleRate and ChannelLayout.
+type InputSpec struct {
+	Format            int
+	TimeBase          avutil.Rational
+	Width             int
+	Height            int
+	SampleAspectRatio avutil.Rational
+	FrameRate         avutil.Rational
+	SampleRate        int
+	ChannelLayout     avutil.ChannelLayout
+}
+
+func (s InputSpec) isAudio() bool {
+	return s.SampleRate > 0 || s.ChannelLayout.NbChannels > 0
+}
+
+//FilterGraph is a configured filter graph whose open inputs are fed through buffer sources
+//and whose open outputs are drained through buffer sinks, both addressed by their label.
+//Unlabeled pads are named "in" and "out", followed by their index if there is more than one.
+//
+//A description containing goproc@name stages is split into one libavfilter graph per segment,
+//frames leaving a segment are handed to the FrameProcessor registered as name and then pushed into the next segment.
+type FilterGraph struct {
+	segments []*graphSegment
+	procs    []FrameProcessor
+	eof      []bool
+	specs    map[string]InputSpec
+	pending  map[string][]*avutil.Frame
+}
+
+//graphSegment is one libavfilter graph of a FilterGraph, with its buffer sources and sinks.
+type graphSegment struct {
+	graph       *C.struct_AVFilterGraph
+	inputs      map[string]*Context
+	outputs     map[string]*Context
+	inputNames  []string
+	outputNames []string
+}
+
+//Create a FilterGraph from a filtergraph description such as "[in]scale=640:360[out]".
+//Every open input of the description must have an entry in inputs.
+func NewFilterGraph(desc string, inputs map[string]InputSpec) (*FilterGraph, error) {
+	parts, err := splitGoProcs(desc)
+	if err != nil {
+		return nil, err
+	}
+	fg := &FilterGraph{specs: inputs}
+	for i, part := range parts {
+		if part.proc != "" {
+			proc := lookupFrameProcessor(part.proc)
+			if proc == nil {
+				fg.Free()
+				return nil, fmt.Errorf("No frame processor registered as %q", part.proc)
+			}
+			fg.procs = append(fg.procs, proc)
+			fg.eof = append(fg.eof, false)
+			continue
+		}
+		var seg *graphSegment
+		if i == 0 {
+			seg, err = newGraphSegment(part.description(inputs), func(name string) (InputSpec, bool) {
+				spec, ok := inputs[name]
+				return spec, ok
+			})
+		} else {
+			seg, err = fg.newInnerSegment(part)
+		}
+		if err != nil {
+			fg.Free()
+			return nil, err
+		}
+		fg.segments = append(fg.segments, seg)
+	}
+	return fg, nil
+}
+
+//Create the segment following a goproc stage, its single input receives the frames of the previous segment.
+func (fg *FilterGraph) newInnerSegment(part graphPart) (*graphSegment, error) {
+	prev := fg.segments[len(fg.segments)-1]
+	if len(prev.outputNames) != 1 {
+		return nil, fmt.Errorf("A goproc stage needs exactly one input, got %d", len(prev.outputNames))
+	}
+	sink := prev.outputs[prev.outputNames[0]]
+	spec := sinkSpec(sink)
+	desc := part.desc
+	if desc == "" {
+		desc = part.passthrough(sink.AvBuffersinkGetType() == avutil.AVMEDIA_TYPE_AUDIO)
+	}
+	seg, err := newGraphSegment(desc, func(string) (InputSpec, bool) {
+		return spec, true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(seg.inputNames) != 1 {
+		seg.free()
+		return nil, fmt.Errorf("A goproc stage needs exactly one output, got %d", len(seg.inputNames))
+	}
+	return seg, nil
+}
+
+//Return the spec of the frames produced by a buffer sink.
+func sinkSpec(sink *Context) InputSpec {
+	spec := InputSpec{
+		Format:   sink.AvBuffersinkGetFormat(),
+		TimeBase: sink.AvBuffersinkGetTimeBase(),
+	}
+	if sink.AvBuffersinkGetType() == avutil.AVMEDIA_TYPE_AUDIO {
+		spec.SampleRate = sink.AvBuffersinkGetSampleRate()
+		spec.ChannelLayout = sink.AvBuffersinkGetChLayout()
+	} else {
+		spec.Width = sink.AvBuffersinkGetW()
+		spec.Height = sink.AvBuffersinkGetH()
+		spec.SampleAspectRatio = sink.AvBuffersinkGetSampleAspectRatio()
+		spec.FrameRate = sink.AvBuffersinkGetFrameRate()
+	}
+	return spec
+}
+
+func newGraphSegment(desc string, spec func(name string) (InputSpec, bool)) (*graphSegment, error) {
+	seg := &graphSegment{
+		graph:   C.avfilter_graph_alloc(),
+		inputs:  map[string]*Context{},
+		outputs: map[string]*Context{},
+	}
+	if seg.graph == nil {
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	if err := seg.build(desc, spec); err != nil {
+		seg.free()
+		return nil, err
+	}
+	return seg, nil
+}
+
+func (seg *graphSegment) build(desc string, specs func(name string) (InputSpec, bool)) error {
+	cdesc := C.CString(desc)
+	defer C.free(unsafe.Pointer(cdesc))
+	var ins, outs *C.struct_AVFilterInOut
+	defer C.avfilter_inout_free(&ins)
+	defer C.avfilter_inout_free(&outs)
+	if ret := C.avfilter_graph_parse2(seg.graph, cdesc, &ins, &outs); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	for i, inout := 0, ins; inout != nil; i, inout = i+1, inout.next {
+		name := padName(inout, "in", i, ins)
+		spec, ok := specs(name)
+		if !ok {
+			return fmt.Errorf("No input spec for filter graph input %q", name)
+		}
+		src, err := seg.newSource(name, MediaType(C.goav_inout_pad_type(inout, 1)), spec)
+		if err != nil {
+			return err
+		}
+		if ret := C.avfilter_link((*C.struct_AVFilterContext)(src), 0, inout.filter_ctx, C.uint(inout.pad_idx)); ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+		seg.inputs[name] = src
+		seg.inputNames = append(seg.inputNames, name)
+	}
+	for i, inout := 0, outs; inout != nil; i, inout = i+1, inout.next {
+		name := padName(inout, "out", i, outs)
+		sink, err := seg.newSink(name, MediaType(C.goav_inout_pad_type(inout, 0)))
+		if err != nil {
+			return err
+		}
+		if ret := C.avfilter_link(inout.filter_ctx, C.uint(inout.pad_idx), (*C.struct_AVFilterContext)(sink), 0); ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+		seg.outputs[name] = sink
+		seg.outputNames = append(seg.outputNames, name)
+	}
+	if ret := C.avfilter_graph_config(seg.graph, nil); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
+
+func padName(inout *C.struct_AVFilterInOut, prefix string, idx int, list *C.struct_AVFilterInOut) string {
+	if inout.name != nil {
+		return C.GoString(inout.name)
+	}
+	if idx == 0 && list.next == nil {
+		return prefix
+	}
+	return fmt.Sprintf("%s%d", prefix, idx)
+}
+
+func (seg *graphSegment) allocFilter(filterName, name string) (*C.struct_AVFilterContext, error) {
+	cfilter := C.CString(filterName)
+	defer C.free(unsafe.Pointer(cfilter))
+	filter := C.avfilter_get_by_name(cfilter)
+	if filter == nil {
+		return nil, fmt.Errorf("Filter %q not found", filterName)
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	ctx := C.avfilter_graph_alloc_filter(seg.graph, filter, cname)
+	if ctx == nil {
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	return ctx, nil
+}
+
+func (seg *graphSegment) newSource(name string, mediaType MediaType, spec InputSpec) (*Context, error) {
+	filterName := "buffer"
+	if mediaType == avutil.AVMEDIA_TYPE_AUDIO {
+		filterName = "abuffer"
+	}
+	cctx, err := seg.allocFilter(filterName, "in_"+name)
+	if err != nil {
+		return nil, err
+	}
+	ctx := (*Context)(cctx)
+	p := AvBuffersrcParametersAlloc()
+	if p == nil {
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	defer p.AvFree()
+	p.SetFormat(spec.Format)
+	p.SetTimeBase(spec.TimeBase)
+	if mediaType == avutil.AVMEDIA_TYPE_AUDIO {
+		p.SetSampleRate(spec.SampleRate)
+		if err := p.SetChLayout(spec.ChannelLayout); err != nil {
+			return nil, err
+		}
+	} else {
+		p.SetWidth(spec.Width)
+		p.SetHeight(spec.Height)
+		p.SetSampleAspectRatio(spec.SampleAspectRatio)
+		p.SetFrameRate(spec.FrameRate)
+	}
+	if ret := ctx.AvBuffersrcParametersSet(p); ret < 0 {
+		return nil, &avutil.Error{Num: ret}
+	}
+	if ret := C.avfilter_init_str(cctx, nil); ret < 0 {
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	return ctx, nil
+}
+
+func (seg *graphSegment) newSink(name string, mediaType MediaType) (*Context, error) {
+	filterName := "buffersink"
+	if mediaType == avutil.AVMEDIA_TYPE_AUDIO {
+		filterName = "abuffersink"
+	}
+	cctx, err := seg.allocFilter(filterName, "out_"+name)
+	if err != nil {
+		return nil, err
+	}
+	if ret := C.avfilter_init_str(cctx, nil); ret < 0 {
+		return nil, &avutil.Error{Num: int(ret)}
+	}
+	return (*Context)(cctx), nil
+}
+
+func (seg *graphSegment) free() {
+	C.avfilter_graph_free(&seg.graph)
+	seg.inputs = nil
+	seg.outputs = nil
+}
+
+//Get the next frame from the named output, ErrAgain and io.EOF are returned as in FilterGraph.Pull().
+func (seg *graphSegment) pull(output string) (*avutil.Frame, error) {
+	sink, ok := seg.outputs[output]
+	if !ok {
+		return nil, fmt.Errorf("Unknown filter graph output %q", output)
+	}
+	f := C.av_frame_alloc()
+	if f == nil {
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	ret := sink.AvBuffersinkGetFrame((*avutil.Frame)(unsafe.Pointer(f)))
+	if ret >= 0 {
+		return (*avutil.Frame)(unsafe.Pointer(f)), nil
+	}
+	C.av_frame_free(&f)
+	switch ret {
+	case avutil.AVERROR_EAGAIN:
+		return nil, ErrAgain
+	case avutil.AVERROR_EOF:
+		return nil, io.EOF
+	}
+	return nil, &avutil.Error{Num: ret}
+}
+
+//Free the graph and all its filters, along with the frames not pulled yet.
+func (fg *FilterGraph) Free() {
+	for _, seg := range fg.segments {
+		seg.free()
+	}
+	fg.segments, fg.procs = nil, nil
+	for _, frames := range fg.pending {
+		for _, f := range frames {
+			cf := (*C.struct_AVFrame)(unsafe.Pointer(f))
+			C.av_frame_free(&cf)
+		}
+	}
+	fg.pending = nil
+}
+
+//Return the first segment, an empty one once the graph was freed, which has no inputs or outputs.
+func (fg *FilterGraph) first() *graphSegment {
+	if len(fg.segments) == 0 {
+		return &graphSegment{}
+	}
+	return fg.segments[0]
+}
+
+func (fg *FilterGraph) last() *graphSegment {
+	if len(fg.segments) == 0 {
+		return &graphSegment{}
+	}
+	return fg.segments[len(fg.segments)-1]
+}
+
+//Return the underlying filter graph, the first one of a description with goproc stages, see Graphs().
+func (fg *FilterGraph) Graph() *Graph {
+	return (*Graph)(fg.first().graph)
+}
+
+//Return the underlying libavfilter graphs, one per segment between goproc stages.
+func (fg *FilterGraph) Graphs() []*Graph {
+	graphs := make([]*Graph, len(fg.segments))
+	for i, seg := range fg.segments {
+		graphs[i] = (*Graph)(seg.graph)
+	}
+	return graphs
+}
+
+//Return the input names in the order they appear in the description.
+func (fg *FilterGraph) Inputs() []string {
+	return append([]string(nil), fg.first().inputNames...)
+}
+
+//Return the output names in the order they appear in the description.
+func (fg *FilterGraph) Outputs() []string {
+	return append([]string(nil), fg.last().outputNames...)
+}
+
+//Return the buffer source feeding the named input, or nil if there is none.
+func (fg *FilterGraph) Source(input string) *Context {
+	return fg.first().inputs[input]
+}
+
+//Return the buffer sink draining the named output, or nil if there is none.
+func (fg *FilterGraph) Sink(output string) *Context {
+	return fg.last().outputs[output]
+}
+
+//Push a frame into the named input. The frame is referenced, the caller keeps ownership of it.
+//A nil frame marks the end of the input, once all inputs are closed Pull() drains the graph and then returns io.EOF.
+func (fg *FilterGraph) Push(input string, f *avutil.Frame) error {
+	src, ok := fg.first().inputs[input]
+	if !ok {
+		return fmt.Errorf("Unknown filter graph input %q", input)
+	}
+	if ret := src.AvBuffersrcAddFrameFlags(f, AV_BUFFERSRC_FLAG_KEEP_REF); ret < 0 {
+		return &avutil.Error{Num: ret}
+	}
+	return fg.pump()
+}
+
+//Move all frames available at the end of each segment through its goproc stage into the next segment.
+func (fg *FilterGraph) pump() error {
+	for i, proc := range fg.procs {
+		from, to := fg.segments[i], fg.segments[i+1]
+		src := to.inputs[to.inputNames[0]]
+		for !fg.eof[i] {
+			f, err := from.pull(from.outputNames[0])
+			if err == ErrAgain {
+				break
+			}
+			if err == io.EOF {
+				fg.eof[i] = true
+				if ret := src.AvBuffersrcAddFrameFlags(nil, 0); ret < 0 {
+					return &avutil.Error{Num: ret}
+				}
+				break
+			}
+			if err != nil {
+				return err
+			}
+			err = runFrameProcessor(proc, f, from.outputs[from.outputNames[0]].AvBuffersinkGetTimeBase())
+			if err == nil {
+				if ret := src.AvBuffersrcAddFrameFlags(f, 0); ret < 0 {
+					err = &avutil.Error{Num: ret}
+				}
+			}
+			cf := (*C.struct_AVFrame)(unsafe.Pointer(f))
+			C.av_frame_free(&cf)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//Pull the next frame from the named output. The returned frame is owned by the caller.
+//ErrAgain is returned when more input is needed, io.EOF once the output reached the end of the stream.
+func (fg *FilterGraph) Pull(output string) (*avutil.Frame, error) {
+	if frames := fg.pending[output]; len(frames) > 0 {
+		fg.pending[output] = frames[1:]
+		return frames[0], nil
+	}
+	return fg.last().pull(output)
+}
@@ -0,0 +1,90 @@
+package avfilter
+
+import (
+	"io"
+	"testing"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avutil"
+)
+
+func TestGoProcStage(t *testing.T) {
+	yuv420p := avcodec.PixelFormat(avcodec.AV_PIX_FMT_YUV420P)
+	src, err := TestSrc2(VideoSourceSpec{Width: 64, Height: 48, FrameRate: avutil.NewRational(25, 1), PixelFormat: &yuv420p})
+	if err != nil {
+		t.Fatalf("TestSrc2() failed: %#v", err)
+	}
+	defer src.Free()
+
+	var seen []int64
+	RegisterFrameProcessor("x", func(f *avutil.Frame, timeBase avutil.Rational) error {
+		seen = append(seen, f.Pts())
+		return nil
+	})
+	defer RegisterFrameProcessor("x", nil)
+	fg, err := NewFilterGraph("null,goproc@x,null", map[string]InputSpec{"in": {
+		Width: 64, Height: 48, TimeBase: src.TimeBase(), SampleAspectRatio: avutil.NewRational(1, 1)}})
+	if err != nil {
+		t.Fatalf("NewFilterGraph() failed: %#v", err)
+	}
+	defer fg.Free()
+	if n := len(fg.Graphs()); n != 2 {
+		t.Errorf("%d graphs, want 2", n)
+	}
+	if fg.Graph() == nil {
+		t.Error("No graph")
+	}
+
+	var pushed, pulled []int64
+	pull := func() {
+		for {
+			f, err := fg.Pull("out")
+			if err == ErrAgain || err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatalf("Pull() failed: %#v", err)
+			}
+			pulled = append(pulled, f.Pts())
+			avutil.AvFrameFree(f)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		f, err := src.Next()
+		if err != nil {
+			t.Fatalf("Next() failed: %#v", err)
+		}
+		pushed = append(pushed, f.Pts())
+		err = fg.Push("in", f)
+		avutil.AvFrameFree(f)
+		if err != nil {
+			t.Fatalf("Push() failed: %#v", err)
+		}
+		pull()
+	}
+	if err := fg.Push("in", nil); err != nil {
+		t.Fatalf("Push() of the end of the input failed: %#v", err)
+	}
+	pull()
+	if _, err := fg.Pull("out"); err != io.EOF {
+		t.Errorf("Pull() after the end returned %#v, want io.EOF", err)
+	}
+
+	equal := func(a, b []int64) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+	if !equal(seen, pushed) {
+		t.Errorf("Processor saw timestamps %v, want %v", seen, pushed)
+	}
+	if !equal(pulled, pushed) {
+		t.Errorf("Pulled timestamps %v, want %v", pulled, pushed)
+	}
+}
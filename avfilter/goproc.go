@@ -0,0 +1,167 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avfilter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+//FrameProcessor is a Go filter used as a goproc@name stage of a FilterGraph, e.g. "scale=640:-1,goproc@redact,format=yuv420p".
+//It receives every frame flowing through the stage, writable so it may be modified in place,
+//along with the time base of the frame timestamps. Returning an error aborts FilterGraph.Push().
+type FrameProcessor func(f *avutil.Frame, timeBase avutil.Rational) error
+
+const goProcPrefix = "goproc@"
+
+var (
+	frameProcessorsMu sync.RWMutex
+	frameProcessors   = map[string]FrameProcessor{}
+)
+
+//Register a FrameProcessor under the given name, making goproc@name usable in filter graph descriptions.
+//Registering nil removes the processor.
+func RegisterFrameProcessor(name string, p FrameProcessor) {
+	frameProcessorsMu.Lock()
+	defer frameProcessorsMu.Unlock()
+	if p == nil {
+		delete(frameProcessors, name)
+		return
+	}
+	frameProcessors[name] = p
+}
+
+func lookupFrameProcessor(name string) FrameProcessor {
+	frameProcessorsMu.RLock()
+	defer frameProcessorsMu.RUnlock()
+	return frameProcessors[name]
+}
+
+func runFrameProcessor(p FrameProcessor, f *avutil.Frame, timeBase avutil.Rational) error {
+	if ret := avutil.AvFrameMakeWritable(f); ret < 0 {
+		return &avutil.Error{Num: ret}
+	}
+	return p(f, timeBase)
+}
+
+//graphPart is either a filtergraph description or a goproc stage of a FilterGraph description.
+//An empty description stands for a pass-through filter carrying the labels of the adjacent goproc stage.
+type graphPart struct {
+	desc      string
+	proc      string
+	inLabels  string
+	outLabels string
+}
+
+func (p graphPart) passthrough(audio bool) string {
+	if audio {
+		return p.inLabels + "anull" + p.outLabels
+	}
+	return p.inLabels + "null" + p.outLabels
+}
+
+//Return the description of the first part, the media type of a pass-through comes from the input spec.
+func (p graphPart) description(inputs map[string]InputSpec) string {
+	if p.desc != "" {
+		return p.desc
+	}
+	name := "in"
+	if p.inLabels != "" {
+		name = strings.Trim(p.inLabels, "[]")
+	}
+	return p.passthrough(inputs[name].isAudio())
+}
+
+//Split a filtergraph description around its goproc stages.
+//goproc stages are only supported within a single linear filter chain.
+func splitGoProcs(desc string) ([]graphPart, error) {
+	if !strings.Contains(desc, goProcPrefix) {
+		return []graphPart{{desc: desc}}, nil
+	}
+	chains := splitTopLevel(desc, ';')
+	found := false
+	for _, chain := range chains {
+		for _, filter := range splitTopLevel(chain, ',') {
+			if _, name, _ := splitLabels(filter); strings.HasPrefix(name, goProcPrefix) {
+				found = true
+			}
+		}
+	}
+	if !found {
+		return []graphPart{{desc: desc}}, nil
+	}
+	if len(chains) != 1 {
+		return nil, fmt.Errorf("goproc stages are only supported in a single linear filter chain")
+	}
+	filters := splitTopLevel(chains[0], ',')
+	var parts []graphPart
+	var cur []string
+	pending := graphPart{}
+	for i, filter := range filters {
+		in, name, out := splitLabels(filter)
+		if !strings.HasPrefix(name, goProcPrefix) {
+			cur = append(cur, filter)
+			continue
+		}
+		if in != "" && i != 0 || out != "" && i != len(filters)-1 {
+			return nil, fmt.Errorf("Labels on %s are only allowed at the ends of the filter chain", name)
+		}
+		pending.desc = strings.Join(cur, ",")
+		pending.inLabels = in
+		parts = append(parts, pending, graphPart{proc: strings.TrimPrefix(name, goProcPrefix)})
+		cur = nil
+		pending = graphPart{outLabels: out}
+	}
+	pending.desc = strings.Join(cur, ",")
+	return append(parts, pending), nil
+}
+
+//Split s at the separator, ignoring separators that are quoted, escaped or within link labels.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	quoted, label := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			label = true
+		case c == ']':
+			label = false
+		case c == sep && !label:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+//Split a filter of a chain into its input labels, its name and arguments, and its output labels.
+func splitLabels(filter string) (in, name, out string) {
+	name = strings.TrimSpace(filter)
+	for strings.HasPrefix(name, "[") {
+		end := strings.IndexByte(name, ']')
+		if end < 0 {
+			break
+		}
+		in += name[:end+1]
+		name = strings.TrimSpace(name[end+1:])
+	}
+	for strings.HasSuffix(name, "]") {
+		start := strings.LastIndexByte(name, '[')
+		if start < 0 {
+			break
+		}
+		out = name[start:] + out
+		name = strings.TrimSpace(name[:start])
+	}
+	return in, name, out
+}
@@ -6,12 +6,22 @@ package avfilter
 /*
 	#cgo pkg-config: libavfilter
 	#include <libavfilter/avfilter.h>
+	#include <stdlib.h>
 */
 import "C"
 import (
 	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+const (
+	AVFILTER_CMD_FLAG_ONE  = int(C.AVFILTER_CMD_FLAG_ONE)
+	AVFILTER_CMD_FLAG_FAST = int(C.AVFILTER_CMD_FLAG_FAST)
 )
 
+const commandResponseSize = 4096
+
 //Allocate a filter graph.
 func AvfilterGraphAlloc() *Graph {
 	return (*Graph)(C.avfilter_graph_alloc())
@@ -63,8 +73,35 @@ func (g *Graph) AvfilterGraphSendCommand(t, cmd, arg, res string, resl, f int) i
 }
 
 //Queue a command for one or more filter instances.
-func (g *Graph) AvfilterGraphQueueCommand(t, cmd, arg string, f int, ts C.double) int {
-	return int(C.avfilter_graph_queue_command((*C.struct_AVFilterGraph)(g), C.CString(t), C.CString(cmd), C.CString(arg), C.int(f), ts))
+func (g *Graph) AvfilterGraphQueueCommand(t, cmd, arg string, f int, ts float64) int {
+	return int(C.avfilter_graph_queue_command((*C.struct_AVFilterGraph)(g), C.CString(t), C.CString(cmd), C.CString(arg), C.int(f), C.double(ts)))
+}
+
+//Send a command to the filter instances matching target, which is an instance name, a filter name or "all".
+//Returns the response of the filters, flags is a combination of AVFILTER_CMD_FLAG_*.
+func (g *Graph) SendCommand(target, cmd, arg string, flags int) (string, error) {
+	ct, ccmd, carg := C.CString(target), C.CString(cmd), C.CString(arg)
+	defer C.free(unsafe.Pointer(ct))
+	defer C.free(unsafe.Pointer(ccmd))
+	defer C.free(unsafe.Pointer(carg))
+	res := (*C.char)(C.calloc(commandResponseSize, 1))
+	defer C.free(unsafe.Pointer(res))
+	if ret := C.avfilter_graph_send_command((*C.struct_AVFilterGraph)(g), ct, ccmd, carg, res, commandResponseSize, C.int(flags)); ret < 0 {
+		return C.GoString(res), &avutil.Error{Num: int(ret)}
+	}
+	return C.GoString(res), nil
+}
+
+//Queue a command for the filter instances matching target, to be executed once the frames reach ts, in seconds.
+func (g *Graph) QueueCommand(target, cmd, arg string, flags int, ts float64) error {
+	ct, ccmd, carg := C.CString(target), C.CString(cmd), C.CString(arg)
+	defer C.free(unsafe.Pointer(ct))
+	defer C.free(unsafe.Pointer(ccmd))
+	defer C.free(unsafe.Pointer(carg))
+	if ret := C.avfilter_graph_queue_command((*C.struct_AVFilterGraph)(g), ct, ccmd, carg, C.int(flags), C.double(ts)); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
 }
 
 //Dump a graph into a human-readable string representation.
@@ -0,0 +1,350 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avfilter
+
+/*
+#cgo pkg-config: libavfilter
+#include <libavfilter/avfilter.h>
+#include <libavfilter/version.h>
+#include <libavutil/channel_layout.h>
+#include <libavutil/mem.h>
+#include <libavutil/opt.h>
+#include <libavutil/pixdesc.h>
+#include <libavutil/samplefmt.h>
+#include <stdlib.h>
+
+static inline AVFilterContext* goav_graph_filter(const AVFilterGraph* g, unsigned int i)
+{
+	return g->filters[i];
+}
+
+static inline AVFilterLink* goav_filter_input(const AVFilterContext* f, unsigned int i)
+{
+	return f->inputs[i];
+}
+
+static inline AVFilterLink* goav_filter_output(const AVFilterContext* f, unsigned int i)
+{
+	return f->outputs[i];
+}
+
+static inline int goav_link_srcpad(const AVFilterLink* l)
+{
+	for (unsigned int i = 0; i < l->src->nb_outputs; i++)
+		if (l->src->outputs[i] == l)
+			return i;
+	return -1;
+}
+
+static inline int goav_link_dstpad(const AVFilterLink* l)
+{
+	for (unsigned int i = 0; i < l->dst->nb_inputs; i++)
+		if (l->dst->inputs[i] == l)
+			return i;
+	return -1;
+}
+
+static inline const char* goav_link_format_name(const AVFilterLink* l)
+{
+	if (l->format < 0)
+		return NULL;
+	if (l->type == AVMEDIA_TYPE_VIDEO)
+		return av_get_pix_fmt_name(l->format);
+	if (l->type == AVMEDIA_TYPE_AUDIO)
+		return av_get_sample_fmt_name(l->format);
+	return NULL;
+}
+
+static inline void goav_link_describe_ch_layout(AVFilterLink* l, char* buf, int size)
+{
+	buf[0] = 0;
+	if (l->type != AVMEDIA_TYPE_AUDIO)
+		return;
+#if LIBAVFILTER_VERSION_INT >= AV_VERSION_INT(8, 44, 100)
+	if (l->ch_layout.nb_channels)
+		av_channel_layout_describe(&l->ch_layout, buf, size);
+#else
+	if (avfilter_link_get_channels(l))
+		av_get_channel_layout_string(buf, size, avfilter_link_get_channels(l), l->channel_layout);
+#endif
+}
+
+static inline char* goav_filter_args(AVFilterContext* f)
+{
+	char* buf = NULL;
+	if (!f->priv || !f->filter->priv_class)
+		return NULL;
+	if (av_opt_serialize(f->priv, AV_OPT_FLAG_FILTERING_PARAM, AV_OPT_SERIALIZE_SKIP_DEFAULTS, &buf, '=', ':') < 0)
+		return NULL;
+	return buf;
+}
+*/
+import "C"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+//GraphInfo describes the topology of a filter graph.
+//On a configured graph the links carry the negotiated formats.
+type GraphInfo struct {
+	Filters            []FilterNode `json:"filters"`
+	Links              []LinkInfo   `json:"links"`
+	UnconnectedInputs  []PadRef     `json:"unconnected_inputs"`
+	UnconnectedOutputs []PadRef     `json:"unconnected_outputs"`
+}
+
+//FilterNode is one filter instance of a graph.
+type FilterNode struct {
+	Name    string    `json:"name"`
+	Filter  string    `json:"filter"`
+	Args    string    `json:"args,omitempty"`
+	Inputs  []PadInfo `json:"inputs"`
+	Outputs []PadInfo `json:"outputs"`
+}
+
+type PadInfo struct {
+	Name      string           `json:"name"`
+	MediaType avutil.MediaType `json:"media_type"`
+}
+
+//PadRef designates a pad of a filter instance, along with its label in the graph description if any.
+type PadRef struct {
+	Label     string           `json:"label,omitempty"`
+	Filter    string           `json:"filter"`
+	Pad       int              `json:"pad"`
+	PadName   string           `json:"pad_name"`
+	MediaType avutil.MediaType `json:"media_type"`
+}
+
+//LinkInfo is a link between an output pad and an input pad, with the properties negotiated for it.
+type LinkInfo struct {
+	Src               string           `json:"src"`
+	SrcPad            int              `json:"src_pad"`
+	Dst               string           `json:"dst"`
+	DstPad            int              `json:"dst_pad"`
+	MediaType         avutil.MediaType `json:"media_type"`
+	Format            string           `json:"format,omitempty"`
+	Width             int              `json:"width,omitempty"`
+	Height            int              `json:"height,omitempty"`
+	SampleAspectRatio avutil.Rational  `json:"sample_aspect_ratio"`
+	SampleRate        int              `json:"sample_rate,omitempty"`
+	ChannelLayout     string           `json:"channel_layout,omitempty"`
+	TimeBase          avutil.Rational  `json:"time_base"`
+}
+
+type GraphFormat int
+
+const (
+	GraphFormatDump GraphFormat = iota
+	GraphFormatJSON
+	GraphFormatDOT
+)
+
+//Return the topology of the graph.
+func (g *Graph) Info() *GraphInfo {
+	cg := (*C.struct_AVFilterGraph)(g)
+	info := &GraphInfo{}
+	for i := C.uint(0); i < cg.nb_filters; i++ {
+		f := C.goav_graph_filter(cg, i)
+		node := FilterNode{
+			Name:   C.GoString(f.name),
+			Filter: C.GoString(f.filter.name),
+		}
+		if args := C.goav_filter_args(f); args != nil {
+			node.Args = C.GoString(args)
+			C.av_free(unsafe.Pointer(args))
+		}
+		for p := C.uint(0); p < f.nb_inputs; p++ {
+			pad := padInfo(f.input_pads, int(p))
+			node.Inputs = append(node.Inputs, pad)
+			if l := C.goav_filter_input(f, p); l != nil {
+				info.Links = append(info.Links, linkInfo(l))
+			} else {
+				info.UnconnectedInputs = append(info.UnconnectedInputs, PadRef{Filter: node.Name, Pad: int(p), PadName: pad.Name, MediaType: pad.MediaType})
+			}
+		}
+		for p := C.uint(0); p < f.nb_outputs; p++ {
+			pad := padInfo(f.output_pads, int(p))
+			node.Outputs = append(node.Outputs, pad)
+			if C.goav_filter_output(f, p) == nil {
+				info.UnconnectedOutputs = append(info.UnconnectedOutputs, PadRef{Filter: node.Name, Pad: int(p), PadName: pad.Name, MediaType: pad.MediaType})
+			}
+		}
+		info.Filters = append(info.Filters, node)
+	}
+	return info
+}
+
+func padInfo(pads *C.struct_AVFilterPad, idx int) PadInfo {
+	return PadInfo{
+		Name:      C.GoString(C.avfilter_pad_get_name(pads, C.int(idx))),
+		MediaType: avutil.MediaType(C.avfilter_pad_get_type(pads, C.int(idx))),
+	}
+}
+
+func linkInfo(l *C.struct_AVFilterLink) LinkInfo {
+	info := LinkInfo{
+		Src:               C.GoString(l.src.name),
+		SrcPad:            int(C.goav_link_srcpad(l)),
+		Dst:               C.GoString(l.dst.name),
+		DstPad:            int(C.goav_link_dstpad(l)),
+		MediaType:         avutil.MediaType(l._type),
+		Width:             int(l.w),
+		Height:            int(l.h),
+		SampleAspectRatio: *((*avutil.Rational)(unsafe.Pointer(&l.sample_aspect_ratio))),
+		SampleRate:        int(l.sample_rate),
+		TimeBase:          *((*avutil.Rational)(unsafe.Pointer(&l.time_base))),
+	}
+	if name := C.goav_link_format_name(l); name != nil {
+		info.Format = C.GoString(name)
+	}
+	var buf [128]C.char
+	C.goav_link_describe_ch_layout(l, &buf[0], C.int(len(buf)))
+	info.ChannelLayout = C.GoString(&buf[0])
+	return info
+}
+
+//Parse a filtergraph description without configuring it, the open inputs and outputs are reported
+//as unconnected pads, labeled as in the description.
+func ParseGraph(desc string) (*GraphInfo, error) {
+	info, _, err := parseGraph(desc)
+	return info, err
+}
+
+//Parse a filtergraph description, also returning the names under which a FilterGraph exposes its open inputs.
+func parseGraph(desc string) (*GraphInfo, []string, error) {
+	g := C.avfilter_graph_alloc()
+	if g == nil {
+		return nil, nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	defer C.avfilter_graph_free(&g)
+	cdesc := C.CString(desc)
+	defer C.free(unsafe.Pointer(cdesc))
+	var ins, outs *C.struct_AVFilterInOut
+	defer C.avfilter_inout_free(&ins)
+	defer C.avfilter_inout_free(&outs)
+	if ret := C.avfilter_graph_parse2(g, cdesc, &ins, &outs); ret < 0 {
+		return nil, nil, &avutil.Error{Num: int(ret)}
+	}
+	info := (*Graph)(g).Info()
+	labelPads(info.UnconnectedInputs, ins)
+	labelPads(info.UnconnectedOutputs, outs)
+	var names []string
+	for i, inout := 0, ins; inout != nil; i, inout = i+1, inout.next {
+		names = append(names, padName(inout, "in", i, ins))
+	}
+	return info, names, nil
+}
+
+func labelPads(pads []PadRef, list *C.struct_AVFilterInOut) {
+	for inout := list; inout != nil; inout = inout.next {
+		if inout.name == nil {
+			continue
+		}
+		for i := range pads {
+			if pads[i].Filter == C.GoString(inout.filter_ctx.name) && pads[i].Pad == int(inout.pad_idx) {
+				pads[i].Label = C.GoString(inout.name)
+			}
+		}
+	}
+}
+
+//Dump the graph into the human-readable representation of avfilter_graph_dump().
+func (g *Graph) Dump() string {
+	s := C.avfilter_graph_dump((*C.struct_AVFilterGraph)(g), nil)
+	if s == nil {
+		return ""
+	}
+	defer C.av_free(unsafe.Pointer(s))
+	return C.GoString(s)
+}
+
+//Describe the graph in the given format.
+func (g *Graph) Describe(format GraphFormat) (string, error) {
+	switch format {
+	case GraphFormatDump:
+		return g.Dump(), nil
+	case GraphFormatJSON:
+		b, err := g.Info().JSON()
+		return string(b), err
+	case GraphFormatDOT:
+		return g.Info().DOT(), nil
+	}
+	return "", fmt.Errorf("Unknown graph format %d", int(format))
+}
+
+func (info *GraphInfo) JSON() ([]byte, error) {
+	return json.MarshalIndent(info, "", "  ")
+}
+
+//Render the graph in the Graphviz DOT language.
+func (info *GraphInfo) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph filtergraph {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, f := range info.Filters {
+		label := f.Name + "\\n(" + f.Filter + ")"
+		if f.Args != "" {
+			label += "\\n" + f.Args
+		}
+		fmt.Fprintf(&b, "\t%s [label=%s];\n", dotQuote(f.Name), dotQuote(label))
+	}
+	for _, l := range info.Links {
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n", dotQuote(l.Src), dotQuote(l.Dst), dotQuote(l.describe()))
+	}
+	for i, p := range info.UnconnectedInputs {
+		id := fmt.Sprintf("input%d", i)
+		fmt.Fprintf(&b, "\t%s [shape=plaintext, label=%s];\n\t%s -> %s;\n", id, dotQuote(p.label()), id, dotQuote(p.Filter))
+	}
+	for i, p := range info.UnconnectedOutputs {
+		id := fmt.Sprintf("output%d", i)
+		fmt.Fprintf(&b, "\t%s [shape=plaintext, label=%s];\n\t%s -> %s;\n", id, dotQuote(p.label()), dotQuote(p.Filter), id)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (l LinkInfo) describe() string {
+	parts := []string{l.MediaType.String()}
+	if l.Format != "" {
+		parts = append(parts, l.Format)
+	}
+	if l.Width > 0 && l.Height > 0 {
+		parts = append(parts, fmt.Sprintf("%dx%d", l.Width, l.Height))
+	}
+	if l.SampleRate > 0 {
+		parts = append(parts, fmt.Sprintf("%dHz", l.SampleRate))
+	}
+	if l.ChannelLayout != "" {
+		parts = append(parts, l.ChannelLayout)
+	}
+	if l.TimeBase.Num() != 0 {
+		parts = append(parts, "tb "+l.TimeBase.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (p PadRef) label() string {
+	if p.Label != "" {
+		return "[" + p.Label + "]"
+	}
+	return p.PadName
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+//Return the topology of each libavfilter graph of the FilterGraph, a single one unless the description uses goproc stages.
+func (fg *FilterGraph) Info() []*GraphInfo {
+	infos := make([]*GraphInfo, len(fg.segments))
+	for i, seg := range fg.segments {
+		infos[i] = (*Graph)(seg.graph).Info()
+	}
+	return infos
+}
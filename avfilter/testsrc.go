@@ -0,0 +1,174 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avfilter
+
+/*
+#cgo pkg-config: libavfilter
+#include <libavutil/pixdesc.h>
+#include <libavutil/samplefmt.h>
+*/
+import "C"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avutil"
+)
+
+//VideoSourceSpec configures the frames of a synthetic video source.
+//Zero values keep the defaults of the source filter, e.g. 320x240 at 25 fps, and a zero Duration never ends the stream.
+//The frames are converted to PixelFormat when it is set, and else keep the format negotiated by the source.
+type VideoSourceSpec struct {
+	Width       int
+	Height      int
+	FrameRate   avutil.Rational
+	Duration    time.Duration
+	PixelFormat *avcodec.PixelFormat
+}
+
+//AudioSourceSpec configures the frames of a synthetic audio source.
+//Zero values keep the defaults of the source filter, e.g. mono at 44100 Hz, and a zero Duration never ends the stream.
+//The frames are converted to SampleFormat when it is set, and else keep the format negotiated by the source.
+//FrameSize is the number of samples of every frame but the last one.
+type AudioSourceSpec struct {
+	SampleRate    int
+	ChannelLayout avutil.ChannelLayout
+	Duration      time.Duration
+	SampleFormat  *avcodec.AvSampleFormat
+	FrameSize     int
+}
+
+//FrameSource generates frames from a lavfi source filter, with no input.
+type FrameSource struct {
+	fg *FilterGraph
+}
+
+//Create a video source from a lavfi source filter with its options, such as "testsrc2" or "mandelbrot=maxiter=100".
+func NewVideoSource(source string, spec VideoSourceSpec) (*FrameSource, error) {
+	var opts []string
+	if spec.Width > 0 && spec.Height > 0 {
+		opts = append(opts, fmt.Sprintf("size=%dx%d", spec.Width, spec.Height))
+	}
+	if spec.FrameRate.Num() > 0 && spec.FrameRate.Den() > 0 {
+		opts = append(opts, "rate="+spec.FrameRate.String())
+	}
+	if spec.Duration > 0 {
+		opts = append(opts, "duration="+formatSeconds(spec.Duration))
+	}
+	desc := withOptions(source, opts)
+	if spec.PixelFormat != nil {
+		name := C.av_get_pix_fmt_name(C.enum_AVPixelFormat(*spec.PixelFormat))
+		if name == nil {
+			return nil, fmt.Errorf("Invalid pixel format %d", int(*spec.PixelFormat))
+		}
+		desc += ",format=pix_fmts=" + C.GoString(name)
+	}
+	return newFrameSource(desc, 0)
+}
+
+//Create an audio source from a lavfi source filter with its options, such as "sine=frequency=440" or "anoisesrc=color=pink".
+func NewAudioSource(source string, spec AudioSourceSpec) (*FrameSource, error) {
+	var opts []string
+	if spec.SampleRate > 0 {
+		opts = append(opts, "sample_rate="+strconv.Itoa(spec.SampleRate))
+	}
+	if spec.Duration > 0 {
+		opts = append(opts, "duration="+formatSeconds(spec.Duration))
+	}
+	desc := withOptions(source, opts)
+	var formats []string
+	if spec.SampleFormat != nil {
+		name := C.av_get_sample_fmt_name(C.enum_AVSampleFormat(*spec.SampleFormat))
+		if name == nil {
+			return nil, fmt.Errorf("Invalid sample format %d", int(*spec.SampleFormat))
+		}
+		formats = append(formats, "sample_fmts="+C.GoString(name))
+	}
+	if spec.ChannelLayout.NbChannels > 0 {
+		formats = append(formats, "channel_layouts="+spec.ChannelLayout.String())
+	}
+	if len(formats) > 0 {
+		desc += ",aformat=" + strings.Join(formats, ":")
+	}
+	return newFrameSource(desc, spec.FrameSize)
+}
+
+//Create a testsrc2 source, a moving test pattern with a frame counter.
+func TestSrc2(spec VideoSourceSpec) (*FrameSource, error) {
+	return NewVideoSource("testsrc2", spec)
+}
+
+//Create a smptebars source, the SMPTE color bars.
+func SMPTEBars(spec VideoSourceSpec) (*FrameSource, error) {
+	return NewVideoSource("smptebars", spec)
+}
+
+//Create a color source producing frames of a uniform color, given by name such as "red" or as "0xRRGGBB[AA]".
+func ColorSource(color string, spec VideoSourceSpec) (*FrameSource, error) {
+	return NewVideoSource("color=color="+color, spec)
+}
+
+//Create a sine source, a sine wave of the given frequency in Hz.
+func Sine(frequency float64, spec AudioSourceSpec) (*FrameSource, error) {
+	return NewAudioSource("sine=frequency="+strconv.FormatFloat(frequency, 'g', -1, 64), spec)
+}
+
+//Create an anoisesrc source of the given noise color, such as "white" or "pink".
+//The noise is generated from seed so that the same seed always produces the same samples.
+func NoiseSource(color string, seed int64, spec AudioSourceSpec) (*FrameSource, error) {
+	return NewAudioSource(fmt.Sprintf("anoisesrc=color=%s:seed=%d", color, seed), spec)
+}
+
+func newFrameSource(desc string, frameSize int) (*FrameSource, error) {
+	fg, err := NewFilterGraph(desc, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(fg.Outputs()) != 1 {
+		fg.Free()
+		return nil, fmt.Errorf("A frame source needs exactly one output, got %d", len(fg.Outputs()))
+	}
+	if frameSize > 0 {
+		fg.Sink(fg.Outputs()[0]).AvBuffersinkSetFrameSize(uint(frameSize))
+	}
+	return &FrameSource{fg: fg}, nil
+}
+
+//Append options to the first filter of a description, which may already have some.
+func withOptions(source string, opts []string) string {
+	if len(opts) == 0 {
+		return source
+	}
+	sep := "="
+	if strings.Contains(source, "=") {
+		sep = ":"
+	}
+	return source + sep + strings.Join(opts, ":")
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+//Return the next frame, owned by the caller, or io.EOF once the configured duration is reached.
+func (s *FrameSource) Next() (*avutil.Frame, error) {
+	return s.fg.Pull(s.fg.Outputs()[0])
+}
+
+//Return the time base of the frame timestamps.
+func (s *FrameSource) TimeBase() avutil.Rational {
+	return s.fg.Sink(s.fg.Outputs()[0]).AvBuffersinkGetTimeBase()
+}
+
+//Return the underlying FilterGraph.
+func (s *FrameSource) FilterGraph() *FilterGraph {
+	return s.fg
+}
+
+func (s *FrameSource) Free() {
+	s.fg.Free()
+}
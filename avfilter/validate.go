@@ -0,0 +1,139 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avfilter
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ValidationErrorKind int
+
+const (
+	//The description could not be parsed.
+	ValidationParseError ValidationErrorKind = iota
+	//A filter of the description does not exist.
+	ValidationUnknownFilter
+	//A goproc stage names a FrameProcessor that is not registered.
+	ValidationUnknownFrameProcessor
+	//An open input of the description has no InputSpec.
+	ValidationMissingInput
+	//The graph could not be configured, e.g. because of invalid filter options or formats that cannot be negotiated.
+	ValidationConfigError
+)
+
+func (k ValidationErrorKind) String() string {
+	switch k {
+	case ValidationParseError:
+		return "parse error"
+	case ValidationUnknownFilter:
+		return "unknown filter"
+	case ValidationUnknownFrameProcessor:
+		return "unknown frame processor"
+	case ValidationMissingInput:
+		return "missing input"
+	case ValidationConfigError:
+		return "config error"
+	}
+	return fmt.Sprintf("ValidationErrorKind(%d)", int(k))
+}
+
+//ValidationError is one problem found in a filtergraph description.
+//Filter is set for unknown filters and frame processors, Input for missing inputs.
+type ValidationError struct {
+	Kind   ValidationErrorKind
+	Filter string
+	Input  string
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	switch {
+	case e.Filter != "":
+		return fmt.Sprintf("%s %q", e.Kind, e.Filter)
+	case e.Input != "":
+		return fmt.Sprintf("%s %q", e.Kind, e.Input)
+	case e.Err != nil:
+		return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+	}
+	return e.Kind.String()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+//ValidationErrors is the error returned by Validate(), listing every problem found.
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+//Check that a description is valid for NewFilterGraph() with the given inputs, without keeping the graph.
+//On success the topology of the configured graphs is returned, see FilterGraph.Info(), otherwise the error is a ValidationErrors.
+func Validate(desc string, inputs map[string]InputSpec) ([]*GraphInfo, error) {
+	errs := checkFilterNames(desc)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	parts, err := splitGoProcs(desc)
+	if err != nil {
+		return nil, ValidationErrors{{Kind: ValidationParseError, Err: err}}
+	}
+	_, names, err := parseGraph(parts[0].description(inputs))
+	if err != nil {
+		return nil, ValidationErrors{{Kind: ValidationParseError, Err: err}}
+	}
+	for _, name := range names {
+		if _, ok := inputs[name]; !ok {
+			errs = append(errs, &ValidationError{Kind: ValidationMissingInput, Input: name})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	fg, err := NewFilterGraph(desc, inputs)
+	if err != nil {
+		return nil, ValidationErrors{{Kind: ValidationConfigError, Err: err}}
+	}
+	defer fg.Free()
+	return fg.Info(), nil
+}
+
+//Look up every filter and goproc stage of a description.
+func checkFilterNames(desc string) ValidationErrors {
+	var errs ValidationErrors
+	for _, chain := range splitTopLevel(desc, ';') {
+		if strings.HasPrefix(strings.TrimSpace(chain), "sws_flags=") {
+			continue
+		}
+		for _, filter := range splitTopLevel(chain, ',') {
+			_, name, _ := splitLabels(filter)
+			if i := strings.IndexByte(name, '='); i >= 0 {
+				name = name[:i]
+			}
+			if name == "" {
+				continue
+			}
+			if strings.HasPrefix(name, goProcPrefix) {
+				if proc := strings.TrimPrefix(name, goProcPrefix); lookupFrameProcessor(proc) == nil {
+					errs = append(errs, &ValidationError{Kind: ValidationUnknownFrameProcessor, Filter: proc})
+				}
+				continue
+			}
+			if i := strings.IndexByte(name, '@'); i >= 0 {
+				name = name[:i]
+			}
+			if AvfilterGetByName(name) == nil {
+				errs = append(errs, &ValidationError{Kind: ValidationUnknownFilter, Filter: name})
+			}
+		}
+	}
+	return errs
+}
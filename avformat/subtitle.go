@@ -0,0 +1,51 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avformat
+
+//#cgo pkg-config: libavformat
+//#include <libavformat/avformat.h>
+import "C"
+import (
+	"unsafe"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avutil"
+)
+
+//Add a stream for the subtitles of enc to the output, before writing the header.
+//Its packets are then written with WriteSubtitle(), alongside those of the video and audio streams.
+func (s *Context) NewSubtitleStream(enc *avcodec.SubtitleEncoder) (*Stream, error) {
+	st := s.AvformatNewStream(nil)
+	if st == nil {
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	if err := enc.CopyParameters(st.CodecPar()); err != nil {
+		return nil, err
+	}
+	st.SetTimeBase(enc.TimeBase())
+	return st, nil
+}
+
+//Encode a subtitle with enc and write its packets to the stream st, such as one added with NewSubtitleStream().
+//The packets are interleaved by the muxer with those of the other streams, so the subtitle should be written
+//when the video reaches its start, as the muxer buffers the other streams until then.
+func (s *Context) WriteSubtitle(st *Stream, enc *avcodec.SubtitleEncoder, sub *avcodec.Subtitle) error {
+	packets, err := enc.Encode(sub)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, p := range packets {
+			avcodec.AvPacketFree(p)
+		}
+	}()
+	for _, p := range packets {
+		p.SetStreamIndex(st.Index())
+		p.AvPacketRescaleTs(enc.TimeBase(), st.TimeBase())
+		if ret := C.av_interleaved_write_frame((*C.struct_AVFormatContext)(s), (*C.struct_AVPacket)(unsafe.Pointer(p))); ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,519 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avutil
+
+/*
+#cgo pkg-config: libavutil
+#include <libavutil/channel_layout.h>
+#include <libavutil/frame.h>
+#include <libavutil/mem.h>
+#include <stdlib.h>
+#include <string.h>
+
+#if LIBAVUTIL_VERSION_INT < AV_VERSION_INT(57, 28, 100)
+// Older libavutil only knows native layouts stored as a channel mask, emulate the
+// AVChannelLayout API on top of it so the Go side does not need to care.
+enum AVChannelOrder {
+	AV_CHANNEL_ORDER_UNSPEC,
+	AV_CHANNEL_ORDER_NATIVE,
+	AV_CHANNEL_ORDER_CUSTOM,
+	AV_CHANNEL_ORDER_AMBISONIC,
+};
+
+typedef struct AVChannelCustom {
+	int id;
+	char name[16];
+	void* opaque;
+} AVChannelCustom;
+
+typedef struct AVChannelLayout {
+	enum AVChannelOrder order;
+	int nb_channels;
+	union {
+		uint64_t mask;
+		AVChannelCustom* map;
+	} u;
+	void* opaque;
+} AVChannelLayout;
+
+static inline void goav_ch_layout_uninit(AVChannelLayout* l)
+{
+	if (l->order == AV_CHANNEL_ORDER_CUSTOM)
+		av_freep(&l->u.map);
+	memset(l, 0, sizeof(*l));
+}
+
+static inline void goav_ch_layout_from_mask(AVChannelLayout* l, uint64_t mask)
+{
+	l->order = AV_CHANNEL_ORDER_NATIVE;
+	l->nb_channels = av_get_channel_layout_nb_channels(mask);
+	l->u.mask = mask;
+}
+
+static inline int goav_ch_layout_from_string(AVChannelLayout* l, const char* s)
+{
+	uint64_t mask = av_get_channel_layout(s);
+	if (!mask)
+		return AVERROR(EINVAL);
+	goav_ch_layout_from_mask(l, mask);
+	return 0;
+}
+
+static inline void goav_ch_layout_default(AVChannelLayout* l, int nb)
+{
+	uint64_t mask = av_get_default_channel_layout(nb);
+	if (mask) {
+		goav_ch_layout_from_mask(l, mask);
+	} else {
+		l->order = AV_CHANNEL_ORDER_UNSPEC;
+		l->nb_channels = nb;
+	}
+}
+
+static inline int goav_ch_layout_describe(const AVChannelLayout* l, char* buf, size_t size)
+{
+	av_get_channel_layout_string(buf, size, l->nb_channels, l->order == AV_CHANNEL_ORDER_NATIVE ? l->u.mask : 0);
+	return strlen(buf) + 1;
+}
+
+static inline int goav_ch_layout_channel(const AVChannelLayout* l, unsigned int idx)
+{
+	if (idx >= l->nb_channels)
+		return -1;
+	switch (l->order) {
+	case AV_CHANNEL_ORDER_NATIVE:
+		return av_log2(av_channel_layout_extract_channel(l->u.mask, idx));
+	case AV_CHANNEL_ORDER_CUSTOM:
+		return l->u.map[idx].id;
+	default:
+		return 0x300;
+	}
+}
+
+static inline int goav_channel_name(char* buf, size_t size, int ch)
+{
+	const char* name = ch >= 0 && ch < 64 ? av_get_channel_name(1ULL << ch) : NULL;
+	return name ? snprintf(buf, size, "%s", name) + 1 : AVERROR(EINVAL);
+}
+
+static inline int goav_channel_description(char* buf, size_t size, int ch)
+{
+	const char* desc = ch >= 0 && ch < 64 ? av_get_channel_description(1ULL << ch) : NULL;
+	return desc ? snprintf(buf, size, "%s", desc) + 1 : AVERROR(EINVAL);
+}
+
+static inline int goav_channel_from_string(const char* s)
+{
+	uint64_t mask = av_get_channel_layout(s);
+	return av_get_channel_layout_nb_channels(mask) == 1 ? av_log2(mask) : -1;
+}
+
+static inline void goav_frame_get_ch_layout(const AVFrame* f, AVChannelLayout* l)
+{
+	if (f->channel_layout) {
+		goav_ch_layout_from_mask(l, f->channel_layout);
+	} else {
+		l->order = AV_CHANNEL_ORDER_UNSPEC;
+		l->nb_channels = f->channels;
+	}
+}
+
+static inline int goav_frame_set_ch_layout(AVFrame* f, const AVChannelLayout* l)
+{
+	f->channel_layout = l->order == AV_CHANNEL_ORDER_NATIVE ? l->u.mask : 0;
+	f->channels = l->nb_channels;
+	return 0;
+}
+#else
+static inline void goav_ch_layout_uninit(AVChannelLayout* l)
+{
+	av_channel_layout_uninit(l);
+}
+
+static inline int goav_ch_layout_from_string(AVChannelLayout* l, const char* s)
+{
+	return av_channel_layout_from_string(l, s);
+}
+
+static inline void goav_ch_layout_default(AVChannelLayout* l, int nb)
+{
+	av_channel_layout_default(l, nb);
+}
+
+static inline int goav_ch_layout_describe(const AVChannelLayout* l, char* buf, size_t size)
+{
+	return av_channel_layout_describe(l, buf, size);
+}
+
+static inline int goav_ch_layout_channel(const AVChannelLayout* l, unsigned int idx)
+{
+	return av_channel_layout_channel_from_index(l, idx);
+}
+
+static inline int goav_channel_name(char* buf, size_t size, int ch)
+{
+	return av_channel_name(buf, size, ch);
+}
+
+static inline int goav_channel_description(char* buf, size_t size, int ch)
+{
+	return av_channel_description(buf, size, ch);
+}
+
+static inline int goav_channel_from_string(const char* s)
+{
+	return av_channel_from_string(s);
+}
+
+static inline void goav_ch_layout_from_mask(AVChannelLayout* l, uint64_t mask)
+{
+	av_channel_layout_from_mask(l, mask);
+}
+
+static inline void goav_frame_get_ch_layout(const AVFrame* f, AVChannelLayout* l)
+{
+	av_channel_layout_copy(l, &f->ch_layout);
+}
+
+static inline int goav_frame_set_ch_layout(AVFrame* f, const AVChannelLayout* l)
+{
+	return av_channel_layout_copy(&f->ch_layout, l);
+}
+#endif
+
+static inline int goav_ch_layout_init(AVChannelLayout* l, int order, int nb, uint64_t mask, const int* map)
+{
+	int i;
+	memset(l, 0, sizeof(*l));
+	l->order = order;
+	l->nb_channels = nb;
+	if (order != AV_CHANNEL_ORDER_CUSTOM) {
+		l->u.mask = mask;
+		return 0;
+	}
+	l->u.map = av_calloc(nb, sizeof(*l->u.map));
+	if (!l->u.map)
+		return AVERROR(ENOMEM);
+	for (i = 0; i < nb; i++)
+		l->u.map[i].id = map[i];
+	return 0;
+}
+
+static inline int goav_ch_layout_order(const AVChannelLayout* l)
+{
+	return l->order;
+}
+
+static inline int goav_ch_layout_nb_channels(const AVChannelLayout* l)
+{
+	return l->nb_channels;
+}
+
+static inline uint64_t goav_ch_layout_mask(const AVChannelLayout* l)
+{
+	return l->order == AV_CHANNEL_ORDER_CUSTOM ? 0 : l->u.mask;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"unsafe"
+)
+
+type (
+	Channel      int
+	ChannelOrder int
+)
+
+const (
+	AV_CHAN_NONE                  = Channel(-1)
+	AV_CHAN_FRONT_LEFT            = Channel(0)
+	AV_CHAN_FRONT_RIGHT           = Channel(1)
+	AV_CHAN_FRONT_CENTER          = Channel(2)
+	AV_CHAN_LOW_FREQUENCY         = Channel(3)
+	AV_CHAN_BACK_LEFT             = Channel(4)
+	AV_CHAN_BACK_RIGHT            = Channel(5)
+	AV_CHAN_FRONT_LEFT_OF_CENTER  = Channel(6)
+	AV_CHAN_FRONT_RIGHT_OF_CENTER = Channel(7)
+	AV_CHAN_BACK_CENTER           = Channel(8)
+	AV_CHAN_SIDE_LEFT             = Channel(9)
+	AV_CHAN_SIDE_RIGHT            = Channel(10)
+	AV_CHAN_TOP_CENTER            = Channel(11)
+	AV_CHAN_TOP_FRONT_LEFT        = Channel(12)
+	AV_CHAN_TOP_FRONT_CENTER      = Channel(13)
+	AV_CHAN_TOP_FRONT_RIGHT       = Channel(14)
+	AV_CHAN_TOP_BACK_LEFT         = Channel(15)
+	AV_CHAN_TOP_BACK_CENTER       = Channel(16)
+	AV_CHAN_TOP_BACK_RIGHT        = Channel(17)
+	AV_CHAN_STEREO_LEFT           = Channel(29)
+	AV_CHAN_STEREO_RIGHT          = Channel(30)
+	AV_CHAN_WIDE_LEFT             = Channel(31)
+	AV_CHAN_WIDE_RIGHT            = Channel(32)
+	AV_CHAN_SURROUND_DIRECT_LEFT  = Channel(33)
+	AV_CHAN_SURROUND_DIRECT_RIGHT = Channel(34)
+	AV_CHAN_LOW_FREQUENCY_2       = Channel(35)
+	AV_CHAN_UNUSED                = Channel(0x200)
+	AV_CHAN_UNKNOWN               = Channel(0x300)
+	AV_CHAN_AMBISONIC_BASE        = Channel(0x400)
+	AV_CHAN_AMBISONIC_END         = Channel(0x7ff)
+
+	AV_CHANNEL_ORDER_UNSPEC    = ChannelOrder(0)
+	AV_CHANNEL_ORDER_NATIVE    = ChannelOrder(1)
+	AV_CHANNEL_ORDER_CUSTOM    = ChannelOrder(2)
+	AV_CHANNEL_ORDER_AMBISONIC = ChannelOrder(3)
+)
+
+//ChannelLayout is a Go copy of AVChannelLayout.
+//With native and ambisonic order the channels are described by Mask, with custom order by Map.
+//When built against a libavutil without AVChannelLayout only unspecified and native layouts are supported by libavutil,
+//and the layout is converted to and from the legacy channel_layout/channels pair.
+type ChannelLayout struct {
+	Order      ChannelOrder
+	NbChannels int
+	Mask       uint64
+	Map        []Channel
+}
+
+//Return the native channel layout for the given legacy channel mask.
+func ChannelLayoutFromMask(mask uint64) ChannelLayout {
+	var cl C.AVChannelLayout
+	C.goav_ch_layout_from_mask(&cl, C.uint64_t(mask))
+	defer C.goav_ch_layout_uninit(&cl)
+	return newChannelLayout(&cl)
+}
+
+//Return the default channel layout for the given number of channels, or an unspecified layout if there is none.
+func DefaultChannelLayout(nbChannels int) ChannelLayout {
+	var cl C.AVChannelLayout
+	C.goav_ch_layout_default(&cl, C.int(nbChannels))
+	defer C.goav_ch_layout_uninit(&cl)
+	return newChannelLayout(&cl)
+}
+
+//Parse a channel layout description such as "stereo", "5.1(side)" or "FL+FR+LFE", the inverse of ChannelLayout.String().
+func ParseChannelLayout(s string) (ChannelLayout, error) {
+	cs := C.CString(s)
+	defer C.free(unsafe.Pointer(cs))
+	var cl C.AVChannelLayout
+	if C.goav_ch_layout_from_string(&cl, cs) < 0 {
+		return ChannelLayout{}, fmt.Errorf("Unknown channel layout %q", s)
+	}
+	defer C.goav_ch_layout_uninit(&cl)
+	return newChannelLayout(&cl), nil
+}
+
+func newChannelLayout(cl *C.AVChannelLayout) ChannelLayout {
+	l := ChannelLayout{
+		Order:      ChannelOrder(C.goav_ch_layout_order(cl)),
+		NbChannels: int(C.goav_ch_layout_nb_channels(cl)),
+		Mask:       uint64(C.goav_ch_layout_mask(cl)),
+	}
+	if l.Order == AV_CHANNEL_ORDER_CUSTOM {
+		l.Map = make([]Channel, l.NbChannels)
+		for i := range l.Map {
+			l.Map[i] = Channel(C.goav_ch_layout_channel(cl, C.uint(i)))
+		}
+	}
+	return l
+}
+
+//Return the layout stored in the AVChannelLayout p points to.
+//Only meaningful when built against a libavutil providing AVChannelLayout.
+func ChannelLayoutAt(p unsafe.Pointer) ChannelLayout {
+	return newChannelLayout((*C.AVChannelLayout)(p))
+}
+
+//Copy the layout into the AVChannelLayout p points to, releasing its previous contents.
+//Only meaningful when built against a libavutil providing AVChannelLayout.
+func (l ChannelLayout) CopyTo(p unsafe.Pointer) error {
+	var cl C.AVChannelLayout
+	if err := l.cLayout(&cl); err != nil {
+		return err
+	}
+	C.goav_ch_layout_uninit((*C.AVChannelLayout)(p))
+	*(*C.AVChannelLayout)(p) = cl
+	return nil
+}
+
+//Fill cl with the layout, cl must be released with goav_ch_layout_uninit().
+func (l ChannelLayout) cLayout(cl *C.AVChannelLayout) error {
+	var cmap *C.int
+	if l.Order == AV_CHANNEL_ORDER_CUSTOM {
+		if len(l.Map) != l.NbChannels {
+			return fmt.Errorf("Channel map has %d entries for %d channels", len(l.Map), l.NbChannels)
+		}
+		m := make([]C.int, len(l.Map))
+		for i, ch := range l.Map {
+			m[i] = C.int(ch)
+		}
+		if len(m) > 0 {
+			cmap = &m[0]
+		}
+	}
+	if ret := C.goav_ch_layout_init(cl, C.int(l.Order), C.int(l.NbChannels), C.uint64_t(l.Mask), cmap); ret < 0 {
+		return &Error{Num: int(ret)}
+	}
+	return nil
+}
+
+//Return the legacy channel mask of the layout, or 0 if the layout cannot be expressed as one.
+func (l ChannelLayout) LegacyMask() uint64 {
+	switch l.Order {
+	case AV_CHANNEL_ORDER_NATIVE:
+		return l.Mask
+	case AV_CHANNEL_ORDER_CUSTOM:
+		var mask uint64
+		for i, ch := range l.Map {
+			if ch < 0 || ch >= 64 || (i > 0 && ch <= l.Map[i-1]) {
+				return 0
+			}
+			mask |= 1 << uint(ch)
+		}
+		return mask
+	}
+	return 0
+}
+
+//Return the channel at the given index, AV_CHAN_NONE if the index is out of range.
+func (l ChannelLayout) Channel(idx int) Channel {
+	if idx < 0 || idx >= l.NbChannels {
+		return AV_CHAN_NONE
+	}
+	switch l.Order {
+	case AV_CHANNEL_ORDER_NATIVE:
+		for ch := Channel(0); ch < 64; ch++ {
+			if l.Mask&(1<<uint(ch)) == 0 {
+				continue
+			}
+			if idx == 0 {
+				return ch
+			}
+			idx--
+		}
+		return AV_CHAN_NONE
+	case AV_CHANNEL_ORDER_CUSTOM:
+		return l.Map[idx]
+	case AV_CHANNEL_ORDER_AMBISONIC:
+		return AV_CHAN_AMBISONIC_BASE + Channel(idx)
+	}
+	return AV_CHAN_UNKNOWN
+}
+
+//Return the channels of the layout in order.
+func (l ChannelLayout) Channels() []Channel {
+	channels := make([]Channel, l.NbChannels)
+	for i := range channels {
+		channels[i] = l.Channel(i)
+	}
+	return channels
+}
+
+//Return the index of the channel in the layout, or -1 if the layout does not contain it.
+func (l ChannelLayout) Index(ch Channel) int {
+	for i := 0; i < l.NbChannels; i++ {
+		if l.Channel(i) == ch {
+			return i
+		}
+	}
+	return -1
+}
+
+//Describe the layout as libavutil does, e.g. "stereo", "5.1(side)" or "3 channels (FL+FR+LFE)".
+func (l ChannelLayout) String() string {
+	var cl C.AVChannelLayout
+	if err := l.cLayout(&cl); err != nil {
+		return fmt.Sprintf("ChannelLayout(%d channels)", l.NbChannels)
+	}
+	defer C.goav_ch_layout_uninit(&cl)
+	size := C.int(64)
+	for {
+		buf := (*C.char)(C.malloc(C.size_t(size)))
+		ret := C.goav_ch_layout_describe(&cl, buf, C.size_t(size))
+		if ret > 0 && ret <= size {
+			s := C.GoString(buf)
+			C.free(unsafe.Pointer(buf))
+			return s
+		}
+		C.free(unsafe.Pointer(buf))
+		if ret <= 0 {
+			return fmt.Sprintf("ChannelLayout(%d channels)", l.NbChannels)
+		}
+		size = ret
+	}
+}
+
+func (l ChannelLayout) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *ChannelLayout) UnmarshalText(text []byte) error {
+	parsed, err := ParseChannelLayout(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+//Return the abbreviated name of the channel, e.g. "FL" or "LFE".
+func (ch Channel) String() string {
+	if s, ok := channelString(ch, false); ok {
+		return s
+	}
+	return fmt.Sprintf("Channel(%d)", int(ch))
+}
+
+//Return a human readable description of the channel, e.g. "front left".
+func (ch Channel) Description() string {
+	if s, ok := channelString(ch, true); ok {
+		return s
+	}
+	return ch.String()
+}
+
+func channelString(ch Channel, description bool) (string, bool) {
+	var buf [64]C.char
+	var ret C.int
+	if description {
+		ret = C.goav_channel_description(&buf[0], C.size_t(len(buf)), C.int(ch))
+	} else {
+		ret = C.goav_channel_name(&buf[0], C.size_t(len(buf)), C.int(ch))
+	}
+	if ret < 0 {
+		return "", false
+	}
+	return C.GoString(&buf[0]), true
+}
+
+//Return the channel with the given abbreviated name, the inverse of Channel.String().
+func ParseChannel(name string) (Channel, error) {
+	cs := C.CString(name)
+	defer C.free(unsafe.Pointer(cs))
+	ch := Channel(C.goav_channel_from_string(cs))
+	if ch == AV_CHAN_NONE {
+		return ch, fmt.Errorf("Unknown channel %q", name)
+	}
+	return ch, nil
+}
+
+//Return the channel layout of an audio frame.
+func (f *Frame) ChLayout() ChannelLayout {
+	var cl C.AVChannelLayout
+	C.goav_frame_get_ch_layout((*C.struct_AVFrame)(unsafe.Pointer(f)), &cl)
+	defer C.goav_ch_layout_uninit(&cl)
+	return newChannelLayout(&cl)
+}
+
+//Set the channel layout of an audio frame.
+func (f *Frame) SetChLayout(l ChannelLayout) error {
+	var cl C.AVChannelLayout
+	if err := l.cLayout(&cl); err != nil {
+		return err
+	}
+	defer C.goav_ch_layout_uninit(&cl)
+	if ret := C.goav_frame_set_ch_layout((*C.struct_AVFrame)(unsafe.Pointer(f)), &cl); ret < 0 {
+		return &Error{Num: int(ret)}
+	}
+	return nil
+}
@@ -12,6 +12,11 @@ func AvDictSet(d **Dictionary, key, value string, flags int) int {
 	return int(C.av_dict_set(unsafe.Pointer(d), C.CString(key), C.CString(value), C.int(flags)))
 }
 
+//Free the dictionary and all its entries, setting d to nil.
+func AvDictFree(d **Dictionary) {
+	C.av_dict_free((**C.struct_AVDictionary)(unsafe.Pointer(d)))
+}
+
 func AvDictGet(d *Dictionary, key string, prev *DictionaryEntry, flags int) *DictionaryEntry {
 	return (*DictionaryEntry)(C.av_dict_get(unsafe.Pointer(d), C.CString(key), unsafe.Pointer(prev), C.int(flags)))
 }
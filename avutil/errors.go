@@ -3,6 +3,8 @@ package avutil
 const (
 	AVERROR_EAGAIN = -11
 	AVERROR_ENOMEM = -12
+	AVERROR_EINVAL = -22
+	AVERROR_ENOSYS = -38
 	AVERROR_EOF = -541478725
 )
 
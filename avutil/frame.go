@@ -39,9 +39,10 @@ func AvFrameAlloc() *Frame {
 	return (*Frame)(unsafe.Pointer(C.av_frame_alloc()))
 }
 
-//Free the frame and any dynamically allocated objects in it, e.g.
+//Free the frame and its buffers, f is no longer valid after this call.
 func AvFrameFree(f *Frame) {
-	C.av_frame_free((**C.struct_AVFrame)(unsafe.Pointer(f)))
+	cf := (*C.struct_AVFrame)(unsafe.Pointer(f))
+	C.av_frame_free(&cf)
 }
 
 //Allocate new buffer(s) for audio or video data.
@@ -21,3 +21,11 @@ func (f *Frame) SetWidth(width int) {
 func (f *Frame) SetHeight(height int) {
 	f.height = C.int(height)
 }
+
+func (f *Frame) NbSamples() int {
+	return int(f.nb_samples)
+}
+
+func (f *Frame) SampleRate() int {
+	return int(f.sample_rate)
+}
@@ -0,0 +1,82 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avutil
+
+//#cgo pkg-config: libavutil
+//#include <libavutil/mathematics.h>
+import "C"
+import (
+	"time"
+)
+
+type AvRounding C.enum_AVRounding
+
+const (
+	AV_ROUND_ZERO        = AvRounding(C.AV_ROUND_ZERO)
+	AV_ROUND_INF         = AvRounding(C.AV_ROUND_INF)
+	AV_ROUND_DOWN        = AvRounding(C.AV_ROUND_DOWN)
+	AV_ROUND_UP          = AvRounding(C.AV_ROUND_UP)
+	AV_ROUND_NEAR_INF    = AvRounding(C.AV_ROUND_NEAR_INF)
+	AV_ROUND_PASS_MINMAX = AvRounding(C.AV_ROUND_PASS_MINMAX)
+)
+
+const (
+	//Undefined timestamp value, INT64_MIN.
+	AV_NOPTS_VALUE = int64(-0x8000000000000000)
+	//Internal time base represented as integer.
+	AV_TIME_BASE = 1000000
+)
+
+//NoPtsDuration is the time.Duration counterpart of AV_NOPTS_VALUE, see Rational.Duration().
+const NoPtsDuration = time.Duration(AV_NOPTS_VALUE)
+
+var nanosecondTimeBase = NewRational(1, int(time.Second))
+
+//Compute the greatest common divisor of two integer operands.
+func AvGcd(a, b int64) int64 {
+	return int64(C.av_gcd(C.int64_t(a), C.int64_t(b)))
+}
+
+//Rescale a 64-bit integer with rounding to nearest, a * b / c.
+func AvRescale(a, b, c int64) int64 {
+	return int64(C.av_rescale(C.int64_t(a), C.int64_t(b), C.int64_t(c)))
+}
+
+//Rescale a 64-bit integer with specified rounding, a * b / c.
+func AvRescaleRnd(a, b, c int64, rnd AvRounding) int64 {
+	return int64(C.av_rescale_rnd(C.int64_t(a), C.int64_t(b), C.int64_t(c), (C.enum_AVRounding)(rnd)))
+}
+
+//Rescale a 64-bit integer by 2 rational numbers, a * bq / cq.
+func AvRescaleQ(a int64, bq, cq Rational) int64 {
+	return int64(C.av_rescale_q(C.int64_t(a), (C.struct_AVRational)(bq), (C.struct_AVRational)(cq)))
+}
+
+//Rescale a 64-bit integer by 2 rational numbers with specified rounding.
+func AvRescaleQRnd(a int64, bq, cq Rational, rnd AvRounding) int64 {
+	return int64(C.av_rescale_q_rnd(C.int64_t(a), (C.struct_AVRational)(bq), (C.struct_AVRational)(cq), (C.enum_AVRounding)(rnd)))
+}
+
+//Compare two timestamps each in its own time base: -1 if ts_a is before ts_b, 1 if ts_a is after ts_b and 0 if they represent the same position.
+func AvCompareTs(tsA int64, tbA Rational, tsB int64, tbB Rational) int {
+	return int(C.av_compare_ts(C.int64_t(tsA), (C.struct_AVRational)(tbA), C.int64_t(tsB), (C.struct_AVRational)(tbB)))
+}
+
+//Rescale a timestamp from time base r to time base to, rounding to nearest.
+//AV_NOPTS_VALUE is passed through unchanged.
+func (r Rational) Rescale(ts int64, to Rational) int64 {
+	return AvRescaleQRnd(ts, r, to, AV_ROUND_NEAR_INF|AV_ROUND_PASS_MINMAX)
+}
+
+//Convert a timestamp in time base r to a time.Duration.
+//AV_NOPTS_VALUE is converted to NoPtsDuration.
+func (r Rational) Duration(ts int64) time.Duration {
+	return time.Duration(r.Rescale(ts, nanosecondTimeBase))
+}
+
+//Convert a time.Duration to a timestamp in time base r.
+//NoPtsDuration is converted to AV_NOPTS_VALUE.
+func (r Rational) Timestamp(d time.Duration) int64 {
+	return nanosecondTimeBase.Rescale(int64(d), r)
+}
@@ -0,0 +1,45 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avutil
+
+//#cgo pkg-config: libavutil
+//#include <libavutil/avutil.h>
+import "C"
+import (
+	"fmt"
+)
+
+//Return the name of the media type as used by libavutil, e.g. "video" or "audio".
+func (m MediaType) String() string {
+	if s := C.av_get_media_type_string((C.enum_AVMediaType)(m)); s != nil {
+		return C.GoString(s)
+	}
+	return fmt.Sprintf("MediaType(%d)", int(m))
+}
+
+//Return the media type matching the given name, the inverse of MediaType.String().
+func ParseMediaType(name string) (MediaType, error) {
+	for m := MediaType(AVMEDIA_TYPE_VIDEO); m < AVMEDIA_TYPE_NB; m++ {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return AVMEDIA_TYPE_UNKNOWN, fmt.Errorf("Unknown media type %q", name)
+}
+
+func (m MediaType) MarshalText() ([]byte, error) {
+	if C.av_get_media_type_string((C.enum_AVMediaType)(m)) == nil {
+		return nil, fmt.Errorf("Invalid media type %d", int(m))
+	}
+	return []byte(m.String()), nil
+}
+
+func (m *MediaType) UnmarshalText(text []byte) error {
+	parsed, err := ParseMediaType(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,288 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avutil
+
+/*
+#cgo pkg-config: libavutil
+#include <libavutil/opt.h>
+#include <libavutil/pixdesc.h>
+#include <libavutil/samplefmt.h>
+
+#if LIBAVUTIL_VERSION_INT >= AV_VERSION_INT(57, 24, 100)
+#define GOAV_OPT_TYPE_CHLAYOUT AV_OPT_TYPE_CHLAYOUT
+#else
+#define GOAV_OPT_TYPE_CHLAYOUT -1
+#endif
+#if LIBAVUTIL_VERSION_MAJOR < 59
+#define GOAV_OPT_TYPE_CHANNEL_LAYOUT AV_OPT_TYPE_CHANNEL_LAYOUT
+#else
+#define GOAV_OPT_TYPE_CHANNEL_LAYOUT -1
+#endif
+#if LIBAVUTIL_VERSION_INT >= AV_VERSION_INT(59, 17, 100)
+#define GOAV_OPT_TYPE_UINT AV_OPT_TYPE_UINT
+#else
+#define GOAV_OPT_TYPE_UINT -1
+#endif
+#if LIBAVUTIL_VERSION_INT >= AV_VERSION_INT(59, 1, 100)
+#define GOAV_OPT_TYPE_FLAG_ARRAY AV_OPT_TYPE_FLAG_ARRAY
+#else
+#define GOAV_OPT_TYPE_FLAG_ARRAY 0
+#endif
+
+static inline const AVOption* goav_class_next_option(const AVClass* class, const AVOption* prev)
+{
+	return av_opt_next(&class, prev);
+}
+
+static inline const AVClass* goav_child_class_iterate(const AVClass* parent, void** iter)
+{
+#if LIBAVUTIL_VERSION_INT >= AV_VERSION_INT(56, 53, 100)
+	return av_opt_child_class_iterate(parent, iter);
+#else
+	const AVClass* c = av_opt_child_class_next(parent, *iter);
+	*iter = (void*)c;
+	return c;
+#endif
+}
+
+static inline int64_t goav_option_default_i64(const AVOption* o)
+{
+	return o->default_val.i64;
+}
+
+static inline double goav_option_default_dbl(const AVOption* o)
+{
+	return o->default_val.dbl;
+}
+
+static inline const char* goav_option_default_str(const AVOption* o)
+{
+#if LIBAVUTIL_VERSION_INT >= AV_VERSION_INT(59, 1, 100)
+	if (o->type & AV_OPT_TYPE_FLAG_ARRAY)
+		return o->default_val.arr ? o->default_val.arr->def : NULL;
+#endif
+	return o->default_val.str;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"strconv"
+	"unsafe"
+)
+
+//OptionType is the type of an AVOption value.
+type OptionType int
+
+//The option types missing from the libavutil version in use are -1.
+var (
+	AV_OPT_TYPE_FLAGS          = OptionType(C.AV_OPT_TYPE_FLAGS)
+	AV_OPT_TYPE_INT            = OptionType(C.AV_OPT_TYPE_INT)
+	AV_OPT_TYPE_INT64          = OptionType(C.AV_OPT_TYPE_INT64)
+	AV_OPT_TYPE_DOUBLE         = OptionType(C.AV_OPT_TYPE_DOUBLE)
+	AV_OPT_TYPE_FLOAT          = OptionType(C.AV_OPT_TYPE_FLOAT)
+	AV_OPT_TYPE_STRING         = OptionType(C.AV_OPT_TYPE_STRING)
+	AV_OPT_TYPE_RATIONAL       = OptionType(C.AV_OPT_TYPE_RATIONAL)
+	AV_OPT_TYPE_BINARY         = OptionType(C.AV_OPT_TYPE_BINARY)
+	AV_OPT_TYPE_DICT           = OptionType(C.AV_OPT_TYPE_DICT)
+	AV_OPT_TYPE_UINT64         = OptionType(C.AV_OPT_TYPE_UINT64)
+	AV_OPT_TYPE_CONST          = OptionType(C.AV_OPT_TYPE_CONST)
+	AV_OPT_TYPE_IMAGE_SIZE     = OptionType(C.AV_OPT_TYPE_IMAGE_SIZE)
+	AV_OPT_TYPE_PIXEL_FMT      = OptionType(C.AV_OPT_TYPE_PIXEL_FMT)
+	AV_OPT_TYPE_SAMPLE_FMT     = OptionType(C.AV_OPT_TYPE_SAMPLE_FMT)
+	AV_OPT_TYPE_VIDEO_RATE     = OptionType(C.AV_OPT_TYPE_VIDEO_RATE)
+	AV_OPT_TYPE_DURATION       = OptionType(C.AV_OPT_TYPE_DURATION)
+	AV_OPT_TYPE_COLOR          = OptionType(C.AV_OPT_TYPE_COLOR)
+	AV_OPT_TYPE_BOOL           = OptionType(C.AV_OPT_TYPE_BOOL)
+	AV_OPT_TYPE_CHLAYOUT       = OptionType(C.GOAV_OPT_TYPE_CHLAYOUT)
+	AV_OPT_TYPE_CHANNEL_LAYOUT = OptionType(C.GOAV_OPT_TYPE_CHANNEL_LAYOUT)
+	AV_OPT_TYPE_UINT           = OptionType(C.GOAV_OPT_TYPE_UINT)
+)
+
+const (
+	AV_OPT_FLAG_ENCODING_PARAM  = int(C.AV_OPT_FLAG_ENCODING_PARAM)
+	AV_OPT_FLAG_DECODING_PARAM  = int(C.AV_OPT_FLAG_DECODING_PARAM)
+	AV_OPT_FLAG_AUDIO_PARAM     = int(C.AV_OPT_FLAG_AUDIO_PARAM)
+	AV_OPT_FLAG_VIDEO_PARAM     = int(C.AV_OPT_FLAG_VIDEO_PARAM)
+	AV_OPT_FLAG_SUBTITLE_PARAM  = int(C.AV_OPT_FLAG_SUBTITLE_PARAM)
+	AV_OPT_FLAG_EXPORT          = int(C.AV_OPT_FLAG_EXPORT)
+	AV_OPT_FLAG_READONLY        = int(C.AV_OPT_FLAG_READONLY)
+	AV_OPT_FLAG_BSF_PARAM       = int(C.AV_OPT_FLAG_BSF_PARAM)
+	AV_OPT_FLAG_RUNTIME_PARAM   = 1 << 15
+	AV_OPT_FLAG_FILTERING_PARAM = int(C.AV_OPT_FLAG_FILTERING_PARAM)
+	AV_OPT_FLAG_DEPRECATED      = 1 << 17
+)
+
+var optionTypeNames = map[OptionType]string{
+	AV_OPT_TYPE_FLAGS:          "flags",
+	AV_OPT_TYPE_INT:            "int",
+	AV_OPT_TYPE_INT64:          "int64",
+	AV_OPT_TYPE_DOUBLE:         "double",
+	AV_OPT_TYPE_FLOAT:          "float",
+	AV_OPT_TYPE_STRING:         "string",
+	AV_OPT_TYPE_RATIONAL:       "rational",
+	AV_OPT_TYPE_BINARY:         "binary",
+	AV_OPT_TYPE_DICT:           "dictionary",
+	AV_OPT_TYPE_UINT64:         "uint64",
+	AV_OPT_TYPE_CONST:          "const",
+	AV_OPT_TYPE_IMAGE_SIZE:     "image_size",
+	AV_OPT_TYPE_PIXEL_FMT:      "pix_fmt",
+	AV_OPT_TYPE_SAMPLE_FMT:     "sample_fmt",
+	AV_OPT_TYPE_VIDEO_RATE:     "video_rate",
+	AV_OPT_TYPE_DURATION:       "duration",
+	AV_OPT_TYPE_COLOR:          "color",
+	AV_OPT_TYPE_BOOL:           "boolean",
+	AV_OPT_TYPE_CHLAYOUT:       "channel_layout",
+	AV_OPT_TYPE_CHANNEL_LAYOUT: "channel_layout",
+	AV_OPT_TYPE_UINT:           "uint",
+}
+
+//Return the name of the option type as printed by "ffmpeg -h", e.g. "int" or "pix_fmt".
+func (t OptionType) String() string {
+	if name, ok := optionTypeNames[t]; ok && t >= 0 {
+		return name
+	}
+	return fmt.Sprintf("OptionType(%d)", int(t))
+}
+
+func (t OptionType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+//Option describes an AVOption of an AVClass.
+//Default is the default value in the syntax accepted when setting the option, empty if there is none.
+//Constants are the named values of the option unit, which may be used instead of a number.
+type Option struct {
+	Name      string           `json:"name"`
+	Help      string           `json:"help,omitempty"`
+	Type      OptionType       `json:"type"`
+	Array     bool             `json:"array,omitempty"`
+	Default   string           `json:"default,omitempty"`
+	Min       float64          `json:"min"`
+	Max       float64          `json:"max"`
+	Flags     int              `json:"flags"`
+	Unit      string           `json:"unit,omitempty"`
+	Constants []OptionConstant `json:"constants,omitempty"`
+}
+
+//OptionConstant is a named value of an option.
+type OptionConstant struct {
+	Name  string `json:"name"`
+	Help  string `json:"help,omitempty"`
+	Value int64  `json:"value"`
+}
+
+//Report whether the option may be changed while the object is running, e.g. with a filter command.
+func (o Option) IsRuntime() bool {
+	return o.Flags&AV_OPT_FLAG_RUNTIME_PARAM != 0
+}
+
+func (o Option) IsDeprecated() bool {
+	return o.Flags&AV_OPT_FLAG_DEPRECATED != 0
+}
+
+//Return the options of an AVClass, class being a const AVClass*, followed by those of its child classes
+//such as the libswresample options of the aresample filter.
+//Named constants are not listed on their own but attached to the options sharing their unit.
+func ClassOptions(class unsafe.Pointer) []Option {
+	if class == nil {
+		return nil
+	}
+	return classOptions((*C.AVClass)(class), nil, map[string]bool{})
+}
+
+func classOptions(c *C.AVClass, opts []Option, seen map[string]bool) []Option {
+	first := len(opts)
+	var consts []*C.AVOption
+	for o := C.goav_class_next_option(c, nil); o != nil; o = C.goav_class_next_option(c, o) {
+		if OptionType(o._type) == AV_OPT_TYPE_CONST {
+			consts = append(consts, o)
+			continue
+		}
+		if name := C.GoString(o.name); !seen[name] {
+			seen[name] = true
+			opts = append(opts, newOption(o))
+		}
+	}
+	for _, o := range consts {
+		unit := C.GoString(o.unit)
+		for i := first; i < len(opts); i++ {
+			if opts[i].Unit == unit && unit != "" {
+				opts[i].Constants = append(opts[i].Constants, OptionConstant{
+					Name:  C.GoString(o.name),
+					Help:  C.GoString(o.help),
+					Value: int64(C.goav_option_default_i64(o)),
+				})
+			}
+		}
+	}
+	var iter unsafe.Pointer
+	for child := C.goav_child_class_iterate(c, &iter); child != nil; child = C.goav_child_class_iterate(c, &iter) {
+		opts = classOptions(child, opts, seen)
+	}
+	return opts
+}
+
+func newOption(o *C.AVOption) Option {
+	t := OptionType(o._type)
+	array := C.GOAV_OPT_TYPE_FLAG_ARRAY != 0 && int(t)&int(C.GOAV_OPT_TYPE_FLAG_ARRAY) != 0
+	if array {
+		t &^= OptionType(C.GOAV_OPT_TYPE_FLAG_ARRAY)
+	}
+	opt := Option{
+		Name:  C.GoString(o.name),
+		Help:  C.GoString(o.help),
+		Type:  t,
+		Array: array,
+		Min:   float64(o.min),
+		Max:   float64(o.max),
+		Flags: int(o.flags),
+		Unit:  C.GoString(o.unit),
+	}
+	if array {
+		opt.Default = C.GoString(C.goav_option_default_str(o))
+		return opt
+	}
+	i64 := int64(C.goav_option_default_i64(o))
+	dbl := float64(C.goav_option_default_dbl(o))
+	switch t {
+	case AV_OPT_TYPE_FLAGS, AV_OPT_TYPE_INT, AV_OPT_TYPE_INT64, AV_OPT_TYPE_UINT:
+		opt.Default = strconv.FormatInt(i64, 10)
+	case AV_OPT_TYPE_UINT64:
+		opt.Default = strconv.FormatUint(uint64(i64), 10)
+	case AV_OPT_TYPE_DOUBLE, AV_OPT_TYPE_FLOAT, AV_OPT_TYPE_RATIONAL:
+		opt.Default = strconv.FormatFloat(dbl, 'g', -1, 64)
+	case AV_OPT_TYPE_BOOL:
+		switch i64 {
+		case 0:
+			opt.Default = "false"
+		case 1:
+			opt.Default = "true"
+		default:
+			opt.Default = "auto"
+		}
+	case AV_OPT_TYPE_PIXEL_FMT:
+		if name := C.av_get_pix_fmt_name(C.enum_AVPixelFormat(i64)); name != nil {
+			opt.Default = C.GoString(name)
+		} else {
+			opt.Default = "none"
+		}
+	case AV_OPT_TYPE_SAMPLE_FMT:
+		if name := C.av_get_sample_fmt_name(C.enum_AVSampleFormat(i64)); name != nil {
+			opt.Default = C.GoString(name)
+		} else {
+			opt.Default = "none"
+		}
+	case AV_OPT_TYPE_DURATION:
+		//In seconds, as the option is set with av_opt_set().
+		opt.Default = strconv.FormatFloat(float64(i64)/1e6, 'f', -1, 64)
+	case AV_OPT_TYPE_CHANNEL_LAYOUT:
+		if i64 != 0 {
+			opt.Default = ChannelLayoutFromMask(uint64(i64)).String()
+		}
+	case AV_OPT_TYPE_STRING, AV_OPT_TYPE_IMAGE_SIZE, AV_OPT_TYPE_VIDEO_RATE, AV_OPT_TYPE_COLOR, AV_OPT_TYPE_CHLAYOUT, AV_OPT_TYPE_DICT:
+		opt.Default = C.GoString(C.goav_option_default_str(o))
+	}
+	return opt
+}
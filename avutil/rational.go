@@ -0,0 +1,121 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package avutil
+
+//#cgo pkg-config: libavutil
+//#include <libavutil/rational.h>
+import "C"
+import (
+	"fmt"
+)
+
+//Create a rational, the Go counterpart of av_make_q().
+func AvMakeQ(num, den int) Rational {
+	return (Rational)(C.av_make_q(C.int(num), C.int(den)))
+}
+
+//Compare two rationals: 0 if a == b, 1 if a > b, -1 if a < b and INT_MIN if one of the values is of the form 0/0.
+func AvCmpQ(a, b Rational) int {
+	return int(C.av_cmp_q((C.struct_AVRational)(a), (C.struct_AVRational)(b)))
+}
+
+//Convert an Rational to a double.
+func AvQ2D(a Rational) float64 {
+	return float64(C.av_q2d((C.struct_AVRational)(a)))
+}
+
+//Reduce a fraction, returns 1 if the operation is exact.
+func AvReduce(n, d *int, num, den, max int64) int {
+	var dn, dd C.int
+	ret := int(C.av_reduce(&dn, &dd, C.int64_t(num), C.int64_t(den), C.int64_t(max)))
+	*n, *d = int(dn), int(dd)
+	return ret
+}
+
+//Multiply two rationals.
+func AvMulQ(b, c Rational) Rational {
+	return (Rational)(C.av_mul_q((C.struct_AVRational)(b), (C.struct_AVRational)(c)))
+}
+
+//Divide one rational by another.
+func AvDivQ(b, c Rational) Rational {
+	return (Rational)(C.av_div_q((C.struct_AVRational)(b), (C.struct_AVRational)(c)))
+}
+
+//Add two rationals.
+func AvAddQ(b, c Rational) Rational {
+	return (Rational)(C.av_add_q((C.struct_AVRational)(b), (C.struct_AVRational)(c)))
+}
+
+//Subtract one rational from another.
+func AvSubQ(b, c Rational) Rational {
+	return (Rational)(C.av_sub_q((C.struct_AVRational)(b), (C.struct_AVRational)(c)))
+}
+
+//Invert a rational.
+func AvInvQ(q Rational) Rational {
+	return (Rational)(C.av_inv_q((C.struct_AVRational)(q)))
+}
+
+//Convert a double precision floating point number to a rational, with max the maximum allowed numerator and denominator.
+func AvD2Q(d float64, max int) Rational {
+	return (Rational)(C.av_d2q(C.double(d), C.int(max)))
+}
+
+//Find which of the two rationals is closer to another rational: 1 if q1 is nearer to q than q2, -1 if q2 is nearer and 0 if they have the same distance.
+func AvNearerQ(q, q1, q2 Rational) int {
+	return int(C.av_nearer_q((C.struct_AVRational)(q), (C.struct_AVRational)(q1), (C.struct_AVRational)(q2)))
+}
+
+func (r Rational) Add(o Rational) Rational {
+	return AvAddQ(r, o)
+}
+
+func (r Rational) Sub(o Rational) Rational {
+	return AvSubQ(r, o)
+}
+
+func (r Rational) Mul(o Rational) Rational {
+	return AvMulQ(r, o)
+}
+
+func (r Rational) Div(o Rational) Rational {
+	return AvDivQ(r, o)
+}
+
+func (r Rational) Inv() Rational {
+	return AvInvQ(r)
+}
+
+//Compare with another rational, see AvCmpQ().
+func (r Rational) Cmp(o Rational) int {
+	return AvCmpQ(r, o)
+}
+
+func (r Rational) Float64() float64 {
+	return AvQ2D(r)
+}
+
+//Report whether the rational has a non zero denominator.
+func (r Rational) IsValid() bool {
+	return r.den != 0
+}
+
+func (r Rational) String() string {
+	return fmt.Sprintf("%d/%d", int(r.num), int(r.den))
+}
+
+func (r Rational) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+//Parse a rational written as "num/den", the inverse of Rational.String().
+func (r *Rational) UnmarshalText(text []byte) error {
+	var num, den int
+	if _, err := fmt.Sscanf(string(text), "%d/%d", &num, &den); err != nil {
+		return fmt.Errorf("Invalid rational %q", text)
+	}
+	*r = NewRational(num, den)
+	return nil
+}
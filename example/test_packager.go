@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io"
+	"log"
+	"time"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avfilter"
+	"github.com/alon-ne/goav/avutil"
+	"github.com/alon-ne/goav/packager"
+	"github.com/alon-ne/goav/swresample"
+	"github.com/alon-ne/goav/swscale"
+)
+
+//Package 20 seconds of a test pattern and a sine wave into an HLS ladder of three renditions with fMP4 segments,
+//logging every file as it is completed.
+func main() {
+	rate := avutil.NewRational(30, 1)
+	yuv420p := avcodec.PixelFormat(avcodec.AV_PIX_FMT_YUV420P)
+	video, err := avfilter.TestSrc2(avfilter.VideoSourceSpec{Width: 1280, Height: 720, FrameRate: rate, Duration: 20 * time.Second,
+		PixelFormat: &yuv420p})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer video.Free()
+	layout := avutil.DefaultChannelLayout(2)
+	fltp := avcodec.AvSampleFormat(avcodec.AV_SAMPLE_FMT_FLTP)
+	audio, err := avfilter.Sine(440, avfilter.AudioSourceSpec{SampleRate: 48000, ChannelLayout: layout, Duration: 20 * time.Second,
+		SampleFormat: &fltp})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer audio.Free()
+
+	p, err := packager.NewPackager(packager.Options{
+		Format:          packager.HLS,
+		Dir:             "hls",
+		SegmentType:     packager.SegmentFMP4,
+		PlaylistType:    packager.PlaylistVOD,
+		SegmentDuration: 4 * time.Second,
+		Renditions: []packager.Rendition{
+			{Name: "720p", Height: 720, VideoBitRate: 3000000, AudioBitRate: 128000},
+			{Name: "480p", Height: 480, VideoBitRate: 1200000, AudioBitRate: 96000},
+			{Name: "240p", Height: 240, VideoBitRate: 400000, AudioBitRate: 64000},
+		},
+		Video: &packager.VideoInput{
+			FrameSpec: swscale.FrameSpec{Width: 1280, Height: 720, PixelFormat: swscale.PixelFormat(avcodec.AV_PIX_FMT_YUV420P)},
+			FrameRate: rate,
+			TimeBase:  video.TimeBase(),
+		},
+		Audio: &swresample.AudioSpec{SampleFormat: swresample.AvSampleFormat(avcodec.AV_SAMPLE_FMT_FLTP), SampleRate: 48000,
+			ChannelLayout: layout, TimeBase: audio.TimeBase()},
+		OnSegment: func(s packager.Segment) {
+			log.Printf("%s of rendition %d: %s", s.Kind, s.Rendition, s.Path)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer p.Free()
+
+	//Interleave the inputs by timestamp, as a decoder of a file would produce them.
+	next := func(src *avfilter.FrameSource) *avutil.Frame {
+		f, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return f
+	}
+	vf, af := next(video), next(audio)
+	for vf != nil || af != nil {
+		if vf != nil && (af == nil || avutil.AvCompareTs(vf.Pts(), video.TimeBase(), af.Pts(), audio.TimeBase()) <= 0) {
+			if err := p.WriteVideoFrame(vf); err != nil {
+				log.Fatal(err)
+			}
+			avutil.AvFrameFree(vf)
+			vf = next(video)
+		} else {
+			if err := p.WriteAudioFrame(af); err != nil {
+				log.Fatal(err)
+			}
+			avutil.AvFrameFree(af)
+			af = next(audio)
+		}
+	}
+	if err := p.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
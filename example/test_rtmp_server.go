@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/rtmpserver"
+)
+
+//Accept publishers of rtmp://127.0.0.1/live/stream and count the packets of each of their streams,
+//publishing sample.mp4 over the loopback interface to it, until interrupted.
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	server := rtmpserver.NewServer(rtmpserver.ServerOptions{
+		App:       "live",
+		StreamKey: "stream",
+		OnReject: func(addr net.Addr, err error) {
+			log.Printf("Rejected %v: %v", addr, err)
+		},
+		OnSessionEnd: func(s *rtmpserver.Session, err error) {
+			log.Printf("Session %d of %v ended: %v, %+v", s.ID, s.RemoteAddr, err, s.Stats())
+		},
+	}, countPackets)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	url := fmt.Sprintf("rtmp://%s/live/stream", l.Addr())
+	go func() {
+		if err := rtmpserver.Publish(ctx, url, "sample.mp4", true); err != nil {
+			log.Printf("Publishing failed: %v", err)
+		}
+		time.Sleep(time.Second)
+		stop()
+	}()
+
+	log.Printf("Listening on %s", url)
+	if err := server.Serve(ctx, l); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+	log.Printf("%+v", server.Metrics())
+}
+
+func countPackets(ctx context.Context, s *rtmpserver.Session) error {
+	log.Printf("Session %d of %v publishing %s/%s", s.ID, s.RemoteAddr, s.App, s.StreamKey)
+	packets := make([]int, s.Input.NbStreams())
+	pkt := avcodec.AvPacketAlloc()
+	defer avcodec.AvPacketFree(pkt)
+	for {
+		err := s.ReadPacket(pkt)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		packets[pkt.StreamIndex()]++
+		pkt.AvPacketUnref()
+	}
+	log.Printf("Session %d packets per stream: %v", s.ID, packets)
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"runtime"
+	"time"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avfilter"
+	"github.com/alon-ne/goav/avutil"
+	"github.com/alon-ne/goav/swscale"
+)
+
+//Downscale a 4K frame to a thumbnail with one thread and with one thread per CPU,
+//checking that both produce the same picture and timing them, see the benchmarks of swscale for accurate figures.
+func main() {
+	yuv420p := avcodec.PixelFormat(avcodec.AV_PIX_FMT_YUV420P)
+	src, err := avfilter.TestSrc2(avfilter.VideoSourceSpec{Width: 3840, Height: 2160, PixelFormat: &yuv420p})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Free()
+	frame, err := src.Next()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer avutil.AvFrameFree(frame)
+
+	in := swscale.FrameSpec{Width: 3840, Height: 2160, PixelFormat: avcodec.AV_PIX_FMT_YUV420P}
+	out := swscale.FrameSpec{Width: 640, Height: 360, PixelFormat: avcodec.AV_PIX_FMT_YUV420P}
+	single, err := swscale.NewScaler(in, out, swscale.SWS_BICUBIC)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer single.Free()
+	parallel, err := swscale.NewScaler(in, out, swscale.SWS_BICUBIC)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer parallel.Free()
+	if err := parallel.SetThreads(runtime.NumCPU()); err != nil {
+		log.Fatal(err)
+	}
+
+	a, b := scale(single, frame), scale(parallel, frame)
+	if !bytes.Equal(a, b) {
+		log.Fatal("Parallel scaling output differs from the single-threaded one")
+	}
+	fmt.Printf("Output of %d threads matches the single-threaded output\n", parallel.Threads())
+
+	for _, s := range []*swscale.Scaler{single, parallel} {
+		dst := avutil.AvFrameAlloc()
+		start := time.Now()
+		for i := 0; i < 10; i++ {
+			if err := s.Scale(dst, frame); err != nil {
+				log.Fatal(err)
+			}
+		}
+		fmt.Printf("%d thread(s):\t%v per frame\n", s.Threads(), time.Since(start)/10)
+		avutil.AvFrameFree(dst)
+	}
+}
+
+func scale(s *swscale.Scaler, frame *avutil.Frame) []byte {
+	dst := avutil.AvFrameAlloc()
+	defer avutil.AvFrameFree(dst)
+	if err := s.Scale(dst, frame); err != nil {
+		log.Fatal(err)
+	}
+	buf, err := avcodec.PackImage(dst, 1)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return buf
+}
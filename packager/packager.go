@@ -0,0 +1,501 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+// Package packager encodes decoded audio and video into the renditions of an HLS or DASH stream, such as an ABR ladder,
+// packaged by the hls and dash muxers of libavformat.
+// The keyframes of all the renditions are forced on the same frames, the first one of every segment,
+// so that their segments are aligned and players can switch between renditions at any segment.
+package packager
+
+/*
+#cgo pkg-config: libavformat libavcodec libavutil
+#include <libavformat/avformat.h>
+#include <libavcodec/avcodec.h>
+#include <libavutil/opt.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avformat"
+	"github.com/alon-ne/goav/avutil"
+	"github.com/alon-ne/goav/swresample"
+	"github.com/alon-ne/goav/swscale"
+)
+
+type (
+	Format       int
+	SegmentType  int
+	PlaylistType int
+)
+
+const (
+	HLS Format = iota
+	DASH
+)
+
+const (
+	//MPEG-TS segments, only supported by HLS.
+	SegmentTS SegmentType = iota
+	//Fragmented MP4 segments, following an initialization segment.
+	SegmentFMP4
+)
+
+const (
+	//A sliding window of the last ListSize segments, for live streams.
+	PlaylistLive PlaylistType = iota
+	//Segments are only appended, so that viewers can seek back to the start of a stream still going on.
+	PlaylistEvent
+	//All the segments, for streams published once complete.
+	PlaylistVOD
+)
+
+//Name of the HLS master playlist and of the DASH manifest.
+const (
+	masterPlaylist = "master.m3u8"
+	manifest       = "manifest.mpd"
+)
+
+func (f Format) String() string {
+	switch f {
+	case HLS:
+		return "hls"
+	case DASH:
+		return "dash"
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+//VideoInput describes the decoded frames given to WriteVideoFrame().
+type VideoInput struct {
+	swscale.FrameSpec
+	//Frame rate of the input, which must be constant, and time base of the frame timestamps, 1/FrameRate by default.
+	FrameRate avutil.Rational
+	TimeBase  avutil.Rational
+}
+
+func (v *VideoInput) timeBase() avutil.Rational {
+	if v.TimeBase.Num() > 0 && v.TimeBase.Den() > 0 {
+		return v.TimeBase
+	}
+	return v.FrameRate.Inv()
+}
+
+//Encryption of the HLS segments with AES-128.
+type Encryption struct {
+	//Key of 16 bytes, never written to the directory of the options.
+	Key []byte
+	//URI players fetch the key from, written in the playlists.
+	KeyURI string
+	//Initialization vector of 16 bytes, the sequence number of every segment when nil.
+	IV []byte
+}
+
+type Options struct {
+	Format Format
+	//Directory the playlists and the segments are written to, created when missing.
+	Dir          string
+	SegmentType  SegmentType
+	PlaylistType PlaylistType
+	//Target duration of the segments, 6 seconds by default. Every segment starts with the first frame past a multiple of it.
+	SegmentDuration time.Duration
+	//Number of segments of a live playlist, 5 by default.
+	ListSize   int
+	Renditions []Rendition
+	//Inputs, nil for a stream without video or without audio. The audio samples must be continuous,
+	//only the timestamp of the first frame is used.
+	Video *VideoInput
+	Audio *swresample.AudioSpec
+	//Encryption of the segments, only supported by HLS with TS segments.
+	Encryption *Encryption
+	//Called with every file once it is complete, from the calls of the packager.
+	OnSegment func(Segment)
+	//Any other option of the hls or dash muxer, such as "hls_flags" or "utc_timing_url",
+	//overriding those set from the fields above.
+	Options map[string]string
+}
+
+//Packager encodes the frames of its inputs into the renditions of a stream, it is not safe for concurrent use.
+type Packager struct {
+	opts       Options
+	oc         *C.AVFormatContext
+	pkt        *C.AVPacket
+	renditions []*rendition
+	//Rendition of every stream of the muxer, and index of every rendition by name.
+	streams []int
+	names   map[string]int
+	keyDir  string
+
+	firstPts, nextKey int64
+	done              bool
+
+	handle uintptr
+	files  map[uintptr]string
+	closed []string
+}
+
+//Create a packager writing the playlists and the segments of the renditions of opts.
+//The files are named after the renditions with HLS, e.g. "720p.m3u8" and "720p_00001.ts" along with "master.m3u8",
+//and after the index of their stream with DASH, e.g. "0_init.m4s" and "0_00001.m4s" along with "manifest.mpd".
+func NewPackager(opts Options) (*Packager, error) {
+	if opts.SegmentDuration <= 0 {
+		opts.SegmentDuration = 6 * time.Second
+	}
+	if opts.ListSize <= 0 {
+		opts.ListSize = 5
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+	p := &Packager{
+		opts:     opts,
+		names:    make(map[string]int),
+		firstPts: avutil.AV_NOPTS_VALUE,
+		files:    make(map[uintptr]string),
+	}
+	if err := p.open(); err != nil {
+		p.Free()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (o *Options) validate() error {
+	if o.Format != HLS && o.Format != DASH {
+		return fmt.Errorf("Unknown packaging format %d", int(o.Format))
+	}
+	if o.Format == DASH && o.SegmentType == SegmentTS {
+		return errors.New("DASH only supports fMP4 segments")
+	}
+	if o.Dir == "" {
+		return errors.New("No directory to write the stream to")
+	}
+	if o.Video == nil && o.Audio == nil {
+		return errors.New("No video or audio input")
+	}
+	if v := o.Video; v != nil {
+		if v.Width <= 0 || v.Height <= 0 {
+			return fmt.Errorf("Invalid video input size %dx%d", v.Width, v.Height)
+		}
+		if v.FrameRate.Num() <= 0 || v.FrameRate.Den() <= 0 {
+			return fmt.Errorf("Invalid video input frame rate %s", v.FrameRate)
+		}
+	}
+	if a := o.Audio; a != nil && (a.SampleRate <= 0 || a.ChannelLayout.NbChannels <= 0) {
+		return fmt.Errorf("Invalid audio input %s", a)
+	}
+	if len(o.Renditions) == 0 {
+		return errors.New("No rendition to package")
+	}
+	names := make(map[string]bool)
+	for i, r := range o.Renditions {
+		name := r.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		if strings.TrimFunc(name, isNameRune) != "" || name+".m3u8" == masterPlaylist {
+			return fmt.Errorf("Invalid rendition name %q", name)
+		}
+		if names[name] {
+			return fmt.Errorf("Duplicate rendition name %q", name)
+		}
+		names[name] = true
+	}
+	if e := o.Encryption; e != nil {
+		if o.Format != HLS || o.SegmentType != SegmentTS {
+			return errors.New("Encryption is only supported by HLS with TS segments")
+		}
+		if len(e.Key) != 16 {
+			return fmt.Errorf("Invalid encryption key of %d bytes, must be 16", len(e.Key))
+		}
+		if e.IV != nil && len(e.IV) != 16 {
+			return fmt.Errorf("Invalid initialization vector of %d bytes, must be 16", len(e.IV))
+		}
+		if e.KeyURI == "" {
+			return errors.New("No URI for the encryption key")
+		}
+	}
+	return nil
+}
+
+func isNameRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-'
+}
+
+//Create the muxer and the encoders of the renditions, and write the header.
+func (p *Packager) open() error {
+	if p.pkt = C.av_packet_alloc(); p.pkt == nil {
+		return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	url := filepath.Join(p.opts.Dir, manifest)
+	if p.opts.Format == HLS {
+		url = filepath.Join(p.opts.Dir, "%v.m3u8")
+	}
+	cformat, curl := C.CString(p.opts.Format.String()), C.CString(url)
+	defer C.free(unsafe.Pointer(cformat))
+	defer C.free(unsafe.Pointer(curl))
+	if ret := C.avformat_alloc_output_context2(&p.oc, nil, cformat, curl); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	p.register()
+
+	for i, r := range p.opts.Renditions {
+		rend := &rendition{name: r.Name}
+		if rend.name == "" {
+			rend.name = strconv.Itoa(i)
+		}
+		p.renditions = append(p.renditions, rend)
+		p.names[rend.name] = i
+		var err error
+		if p.opts.Video != nil {
+			if rend.video, err = p.newVideoEncoder(r); err != nil {
+				return err
+			}
+			p.streams = append(p.streams, i)
+		}
+		if p.opts.Audio != nil {
+			if rend.audio, err = p.newAudioEncoder(r); err != nil {
+				return err
+			}
+			p.streams = append(p.streams, i)
+		}
+	}
+
+	opts, err := p.muxerOptions()
+	if err != nil {
+		return err
+	}
+	for _, o := range opts {
+		if err := p.setOption(o[0], o[1]); err != nil {
+			return err
+		}
+	}
+	for name, value := range p.opts.Options {
+		if err := p.setOption(name, value); err != nil {
+			return err
+		}
+	}
+	ret := C.avformat_write_header(p.oc, nil)
+	p.reportSegments()
+	if ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
+
+//Return the options of the muxer set from the fields of the options, in order.
+func (p *Packager) muxerOptions() ([][2]string, error) {
+	o := p.opts
+	duration := strconv.FormatFloat(o.SegmentDuration.Seconds(), 'f', -1, 64)
+	if o.Format == DASH {
+		opts := [][2]string{
+			{"seg_duration", duration},
+			{"dash_segment_type", "mp4"},
+			{"init_seg_name", "$RepresentationID$_init.$ext$"},
+			{"media_seg_name", "$RepresentationID$_$Number%05d$.$ext$"},
+			{"window_size", "0"},
+		}
+		if o.PlaylistType == PlaylistLive {
+			opts[4][1] = strconv.Itoa(o.ListSize)
+		}
+		var sets []string
+		if o.Video != nil {
+			sets = append(sets, "id=0,streams=v")
+		}
+		if o.Audio != nil {
+			sets = append(sets, fmt.Sprintf("id=%d,streams=a", len(sets)))
+		}
+		return append(opts, [2]string{"adaptation_sets", strings.Join(sets, " ")}), nil
+	}
+
+	opts := [][2]string{
+		{"hls_time", duration},
+		{"hls_list_size", "0"},
+		{"hls_segment_type", "mpegts"},
+		{"hls_segment_filename", filepath.Join(o.Dir, "%v_%05d.ts")},
+		{"master_pl_name", masterPlaylist},
+		{"hls_flags", "independent_segments"},
+	}
+	switch o.PlaylistType {
+	case PlaylistLive:
+		opts[1][1] = strconv.Itoa(o.ListSize)
+	case PlaylistEvent:
+		opts = append(opts, [2]string{"hls_playlist_type", "event"})
+	case PlaylistVOD:
+		opts = append(opts, [2]string{"hls_playlist_type", "vod"})
+	}
+	if o.SegmentType == SegmentFMP4 {
+		opts[2][1] = "fmp4"
+		opts[3][1] = filepath.Join(o.Dir, "%v_%05d.m4s")
+		opts = append(opts, [2]string{"hls_fmp4_init_filename", "%v_init.mp4"})
+	}
+	streams := make([]string, len(p.renditions))
+	for i, r := range p.renditions {
+		var s []string
+		if o.Video != nil {
+			s = append(s, fmt.Sprintf("v:%d", i))
+		}
+		if o.Audio != nil {
+			s = append(s, fmt.Sprintf("a:%d", i))
+		}
+		streams[i] = strings.Join(append(s, "name:"+r.name), ",")
+	}
+	opts = append(opts, [2]string{"var_stream_map", strings.Join(streams, " ")})
+	if o.Encryption != nil {
+		info, err := p.writeKeyInfo()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, [2]string{"hls_key_info_file", info})
+	}
+	return opts, nil
+}
+
+//Write the key and the key info file the hls muxer reads it from to a temporary directory, returning the path of the latter.
+func (p *Packager) writeKeyInfo() (string, error) {
+	e := p.opts.Encryption
+	dir, err := os.MkdirTemp("", "goav-packager")
+	if err != nil {
+		return "", err
+	}
+	p.keyDir = dir
+	key, info := filepath.Join(dir, "segments.key"), filepath.Join(dir, "segments.keyinfo")
+	if err := os.WriteFile(key, e.Key, 0600); err != nil {
+		return "", err
+	}
+	lines := []string{e.KeyURI, key}
+	if e.IV != nil {
+		lines = append(lines, hex.EncodeToString(e.IV))
+	}
+	if err := os.WriteFile(info, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return info, nil
+}
+
+func (p *Packager) setOption(name, value string) error {
+	cname, cvalue := C.CString(name), C.CString(value)
+	defer C.free(unsafe.Pointer(cname))
+	defer C.free(unsafe.Pointer(cvalue))
+	ret := C.av_opt_set(unsafe.Pointer(p.oc), cname, cvalue, C.AV_OPT_SEARCH_CHILDREN)
+	if ret == C.AVERROR_OPTION_NOT_FOUND {
+		return fmt.Errorf("Unknown %s muxer option %q", p.opts.Format, name)
+	}
+	if ret < 0 {
+		return fmt.Errorf("Invalid value %q for %s muxer option %s", value, p.opts.Format, name)
+	}
+	return nil
+}
+
+//Encode a decoded video frame of the video input into every rendition.
+//The frame is a keyframe of all the renditions when it is the first one past a segment boundary, its timestamp being
+//counted from the one of the first frame, and the frames must have increasing timestamps at the input frame rate.
+func (p *Packager) WriteVideoFrame(f *avutil.Frame) error {
+	in := p.opts.Video
+	if in == nil {
+		return errors.New("Packager has no video input")
+	}
+	if p.done {
+		return errors.New("Packager already closed")
+	}
+	if f.Pts() == avutil.AV_NOPTS_VALUE {
+		return errors.New("Video frame without timestamp")
+	}
+	tb := in.FrameRate.Inv()
+	pts := avutil.AvRescaleQ(f.Pts(), in.timeBase(), tb)
+	if p.firstPts == avutil.AV_NOPTS_VALUE {
+		p.firstPts = pts
+	}
+	//Compared as the muxers do to start a new segment.
+	elapsed := pts - p.firstPts
+	key := C.av_compare_ts(C.int64_t(elapsed), C.AVRational{num: C.int(tb.Num()), den: C.int(tb.Den())},
+		C.int64_t(p.nextKey), C.AVRational{num: 1, den: C.AV_TIME_BASE}) >= 0
+	if key {
+		segment := int64(p.opts.SegmentDuration / time.Microsecond)
+		p.nextKey = (avutil.AvRescaleQ(elapsed, tb, avutil.NewRational(1, C.AV_TIME_BASE))/segment + 1) * segment
+	}
+	defer p.reportSegments()
+	for _, r := range p.renditions {
+		if err := p.encodeVideo(r.video, f, pts, key); err != nil {
+			return fmt.Errorf("Rendition %s: %w", r.name, err)
+		}
+	}
+	return nil
+}
+
+//Encode a decoded audio frame of the audio input into every rendition.
+func (p *Packager) WriteAudioFrame(f *avutil.Frame) error {
+	if p.opts.Audio == nil {
+		return errors.New("Packager has no audio input")
+	}
+	if p.done {
+		return errors.New("Packager already closed")
+	}
+	defer p.reportSegments()
+	for _, r := range p.renditions {
+		if err := p.encodeAudio(r.audio, f); err != nil {
+			return fmt.Errorf("Rendition %s: %w", r.name, err)
+		}
+	}
+	return nil
+}
+
+//Encode the frames left in the encoders and finish the playlists, which are final for PlaylistEvent and PlaylistVOD.
+func (p *Packager) Close() error {
+	if p.done {
+		return nil
+	}
+	p.done = true
+	defer p.reportSegments()
+	for _, r := range p.renditions {
+		if r.video != nil {
+			if err := p.encode(r.video, nil); err != nil {
+				return fmt.Errorf("Rendition %s: %w", r.name, err)
+			}
+		}
+		if r.audio != nil {
+			if err := p.encodeAudio(r.audio, nil); err != nil {
+				return fmt.Errorf("Rendition %s: %w", r.name, err)
+			}
+			if err := p.encode(r.audio, nil); err != nil {
+				return fmt.Errorf("Rendition %s: %w", r.name, err)
+			}
+		}
+	}
+	if ret := C.av_write_trailer(p.oc); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
+
+//Return the muxer, which writes the playlists and the segments.
+func (p *Packager) Context() *avformat.Context {
+	return (*avformat.Context)(unsafe.Pointer(p.oc))
+}
+
+func (p *Packager) Free() {
+	p.unregister()
+	for _, r := range p.renditions {
+		r.free()
+	}
+	p.renditions = nil
+	C.avformat_free_context(p.oc)
+	p.oc = nil
+	C.av_packet_free(&p.pkt)
+	if p.keyDir != "" {
+		os.RemoveAll(p.keyDir)
+		p.keyDir = ""
+	}
+}
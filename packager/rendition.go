@@ -0,0 +1,369 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package packager
+
+/*
+#cgo pkg-config: libavformat libavcodec libavutil
+#include <libavformat/avformat.h>
+#include <libavcodec/avcodec.h>
+#include <libavutil/audio_fifo.h>
+#include <libavutil/opt.h>
+#include <stdlib.h>
+
+//Return the first sample format the encoder supports, AV_SAMPLE_FMT_NONE when it does not tell.
+static inline int goav_encoder_sample_fmt(const AVCodec* codec)
+{
+#if LIBAVCODEC_VERSION_INT >= AV_VERSION_INT(61, 13, 100)
+	const void* fmts = NULL;
+	int n = 0;
+	if (avcodec_get_supported_config(NULL, codec, AV_CODEC_CONFIG_SAMPLE_FORMAT, 0, &fmts, &n) < 0 || !fmts)
+		return AV_SAMPLE_FMT_NONE;
+	return ((const enum AVSampleFormat*)fmts)[0];
+#else
+	return codec->sample_fmts ? codec->sample_fmts[0] : AV_SAMPLE_FMT_NONE;
+#endif
+}
+
+static inline int goav_audio_fifo_write(AVAudioFifo* fifo, AVFrame* f)
+{
+	return av_audio_fifo_write(fifo, (void**)f->extended_data, f->nb_samples);
+}
+
+static inline int goav_audio_fifo_read(AVAudioFifo* fifo, AVFrame* f, int n)
+{
+	return av_audio_fifo_read(fifo, (void**)f->extended_data, n);
+}
+*/
+import "C"
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avutil"
+	"github.com/alon-ne/goav/swresample"
+	"github.com/alon-ne/goav/swscale"
+)
+
+//Rendition is one encoding of the input, a step of the ABR ladder.
+type Rendition struct {
+	//Name of the rendition in the names of its files, made of letters, digits and dashes, its index when empty.
+	Name string
+	//Size of the pictures, the input size when both are 0, keeping the aspect ratio of the input when one is 0.
+	Width, Height int
+	//Bit rates in bits per second, the defaults of the encoders when 0.
+	VideoBitRate, AudioBitRate int
+	//Encoders, H.264 and AAC when AV_CODEC_ID_NONE.
+	VideoCodec, AudioCodec avcodec.CodecId
+	//Options of the encoders, such as "preset" or "profile".
+	VideoOptions, AudioOptions map[string]string
+}
+
+type rendition struct {
+	name         string
+	video, audio *encoder
+}
+
+type encoder struct {
+	ctx    *C.AVCodecContext
+	stream *C.AVStream
+
+	//Video frames are scaled to the size of the rendition, when it is not the input size.
+	scaler *swscale.Scaler
+
+	//Audio samples are converted to the format of the encoder and cut into frames of its frame size.
+	resampler *swresample.Resampler
+	layout    avutil.ChannelLayout
+	fifo      *C.AVAudioFifo
+	pts       int64
+}
+
+func (e *encoder) free() {
+	C.avcodec_free_context(&e.ctx)
+	if e.scaler != nil {
+		e.scaler.Free()
+	}
+	if e.resampler != nil {
+		e.resampler.Free()
+	}
+	if e.fifo != nil {
+		C.av_audio_fifo_free(e.fifo)
+		e.fifo = nil
+	}
+}
+
+func (r *rendition) free() {
+	if r.video != nil {
+		r.video.free()
+	}
+	if r.audio != nil {
+		r.audio.free()
+	}
+}
+
+//Return the size of the pictures of r for input pictures of w x h, rounded to even dimensions when scaled.
+func (r Rendition) size(w, h int) (int, int) {
+	even := func(v float64) int {
+		return 2 * int(v/2+0.5)
+	}
+	switch {
+	case r.Width > 0 && r.Height > 0:
+		return r.Width, r.Height
+	case r.Width > 0:
+		return r.Width, even(float64(r.Width) * float64(h) / float64(w))
+	case r.Height > 0:
+		return even(float64(r.Height) * float64(w) / float64(h)), r.Height
+	}
+	return w, h
+}
+
+func findEncoder(id avcodec.CodecId, defaultID int) (*C.AVCodec, error) {
+	if id == avcodec.CodecId(avcodec.AV_CODEC_ID_NONE) {
+		id = avcodec.CodecId(defaultID)
+	}
+	codec := C.avcodec_find_encoder(C.enum_AVCodecID(id))
+	if codec == nil {
+		return nil, fmt.Errorf("No %s encoder", id)
+	}
+	return codec, nil
+}
+
+//Create the encoder of the video of r and its stream, its keyframes being forced by the packager
+//and the interval between the others set to the segment duration.
+func (p *Packager) newVideoEncoder(r Rendition) (*encoder, error) {
+	in := p.opts.Video
+	codec, err := findEncoder(r.VideoCodec, avcodec.AV_CODEC_ID_H264)
+	if err != nil {
+		return nil, err
+	}
+	e := &encoder{ctx: C.avcodec_alloc_context3(codec)}
+	if e.ctx == nil {
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	w, h := r.size(in.Width, in.Height)
+	out := swscale.FrameSpec{Width: w, Height: h, PixelFormat: swscale.PixelFormat(avcodec.AV_PIX_FMT_YUV420P)}
+	if w != in.Width || h != in.Height || out.PixelFormat != in.PixelFormat {
+		if e.scaler, err = swscale.NewScaler(in.FrameSpec, out, swscale.SWS_BICUBIC); err != nil {
+			e.free()
+			return nil, err
+		}
+	}
+	rate := in.FrameRate
+	e.ctx.width, e.ctx.height, e.ctx.pix_fmt = C.int(w), C.int(h), C.enum_AVPixelFormat(out.PixelFormat)
+	e.ctx.sample_aspect_ratio = C.AVRational{num: 1, den: 1}
+	e.ctx.framerate = C.AVRational{num: C.int(rate.Num()), den: C.int(rate.Den())}
+	e.ctx.time_base = C.AVRational{num: C.int(rate.Den()), den: C.int(rate.Num())}
+	e.ctx.colorspace = C.enum_AVColorSpace(in.Colorspace)
+	e.ctx.color_trc = C.enum_AVColorTransferCharacteristic(in.ColorTrc)
+	e.ctx.color_primaries = C.enum_AVColorPrimaries(in.ColorPrimaries)
+	e.ctx.gop_size = C.int((int64(p.opts.SegmentDuration)*int64(rate.Num()) + int64(time.Second)*int64(rate.Den()) - 1) /
+		(int64(time.Second) * int64(rate.Den())))
+	e.ctx.bit_rate = C.int64_t(r.VideoBitRate)
+	//Forced keyframes must be IDR frames for the segments to start with a closed GOP, the option is private to some encoders.
+	e.setOption("forced-idr", "1")
+	if err := p.openEncoder(e, codec, "video", r.VideoOptions); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+//Create the encoder of the audio of r and its stream.
+func (p *Packager) newAudioEncoder(r Rendition) (*encoder, error) {
+	in := *p.opts.Audio
+	codec, err := findEncoder(r.AudioCodec, avcodec.AV_CODEC_ID_AAC)
+	if err != nil {
+		return nil, err
+	}
+	e := &encoder{ctx: C.avcodec_alloc_context3(codec), pts: avutil.AV_NOPTS_VALUE}
+	if e.ctx == nil {
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	out := swresample.AudioSpec{
+		SampleFormat:  swresample.AvSampleFormat(C.goav_encoder_sample_fmt(codec)),
+		SampleRate:    in.SampleRate,
+		ChannelLayout: in.ChannelLayout,
+	}
+	if out.SampleFormat == swresample.AvSampleFormat(C.AV_SAMPLE_FMT_NONE) {
+		out.SampleFormat = in.SampleFormat
+	}
+	e.ctx.sample_fmt = C.enum_AVSampleFormat(out.SampleFormat)
+	e.ctx.sample_rate = C.int(out.SampleRate)
+	e.ctx.time_base = C.AVRational{num: 1, den: C.int(out.SampleRate)}
+	e.ctx.bit_rate = C.int64_t(r.AudioBitRate)
+	if err := (*avcodec.Context)(unsafe.Pointer(e.ctx)).SetChLayout(out.ChannelLayout); err != nil {
+		e.free()
+		return nil, err
+	}
+	if err := p.openEncoder(e, codec, "audio", r.AudioOptions); err != nil {
+		return nil, err
+	}
+	if out.SampleFormat != in.SampleFormat {
+		if e.resampler, err = swresample.NewResampler(in, out, nil); err != nil {
+			e.free()
+			return nil, err
+		}
+	}
+	e.layout = out.ChannelLayout
+	e.fifo = C.av_audio_fifo_alloc(e.ctx.sample_fmt, C.int(e.layout.NbChannels), 1)
+	if e.fifo == nil {
+		e.free()
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	return e, nil
+}
+
+//Set the options of the encoder, open it and add its stream to the muxer, e is freed on failure.
+func (p *Packager) openEncoder(e *encoder, codec *C.AVCodec, kind string, opts map[string]string) error {
+	if p.oc.oformat.flags&C.AVFMT_GLOBALHEADER != 0 {
+		e.ctx.flags |= C.AV_CODEC_FLAG_GLOBAL_HEADER
+	}
+	for name, value := range opts {
+		switch e.setOption(name, value) {
+		case C.AVERROR_OPTION_NOT_FOUND:
+			e.free()
+			return fmt.Errorf("Unknown %s encoder option %q", kind, name)
+		case 0:
+		default:
+			e.free()
+			return fmt.Errorf("Invalid value %q for %s encoder option %s", value, kind, name)
+		}
+	}
+	if ret := C.avcodec_open2(e.ctx, codec, nil); ret < 0 {
+		e.free()
+		return &avutil.Error{Num: int(ret)}
+	}
+	if e.stream = C.avformat_new_stream(p.oc, nil); e.stream == nil {
+		e.free()
+		return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	if ret := C.avcodec_parameters_from_context(e.stream.codecpar, e.ctx); ret < 0 {
+		e.free()
+		return &avutil.Error{Num: int(ret)}
+	}
+	e.stream.time_base = e.ctx.time_base
+	return nil
+}
+
+func (e *encoder) setOption(name, value string) C.int {
+	cname, cvalue := C.CString(name), C.CString(value)
+	defer C.free(unsafe.Pointer(cname))
+	defer C.free(unsafe.Pointer(cvalue))
+	return C.av_opt_set(unsafe.Pointer(e.ctx), cname, cvalue, C.AV_OPT_SEARCH_CHILDREN)
+}
+
+//Encode a video frame of the input, scaled to the size of the rendition, as a keyframe when key is set.
+func (p *Packager) encodeVideo(e *encoder, src *avutil.Frame, pts int64, key bool) error {
+	f := avutil.AvFrameAlloc()
+	defer avutil.AvFrameFree(f)
+	if e.scaler != nil {
+		if err := e.scaler.Scale(f, src); err != nil {
+			return err
+		}
+	} else if ret := avutil.AvFrameRef(f, src); ret < 0 {
+		return &avutil.Error{Num: ret}
+	}
+	cf := (*C.AVFrame)(unsafe.Pointer(f))
+	cf.pts = C.int64_t(pts)
+	cf.pict_type = C.AV_PICTURE_TYPE_NONE
+	if key {
+		cf.pict_type = C.AV_PICTURE_TYPE_I
+	}
+	return p.encode(e, cf)
+}
+
+//Encode the samples of an audio frame of the input, nil to encode those left at the end of the stream.
+func (p *Packager) encodeAudio(e *encoder, src *avutil.Frame) error {
+	if src != nil && e.pts == avutil.AV_NOPTS_VALUE {
+		e.pts = 0
+		if pts := src.Pts(); pts != avutil.AV_NOPTS_VALUE {
+			tb := p.opts.Audio.TimeBase
+			if tb.Num() <= 0 || tb.Den() <= 0 {
+				tb = avutil.NewRational(1, p.opts.Audio.SampleRate)
+			}
+			e.pts = avutil.AvRescaleQ(pts, tb, avutil.NewRational(1, int(e.ctx.sample_rate)))
+		}
+	}
+	flush := src == nil
+	if e.resampler != nil {
+		f := avutil.AvFrameAlloc()
+		defer avutil.AvFrameFree(f)
+		if err := e.resampler.Convert(f, src); err != nil {
+			return err
+		}
+		src = f
+	}
+	if src != nil && src.NbSamples() > 0 {
+		if ret := C.goav_audio_fifo_write(e.fifo, (*C.AVFrame)(unsafe.Pointer(src))); ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+	}
+	size := int(e.ctx.frame_size)
+	if size <= 0 || e.ctx.codec.capabilities&C.AV_CODEC_CAP_VARIABLE_FRAME_SIZE != 0 {
+		size = int(C.av_audio_fifo_size(e.fifo))
+	}
+	for {
+		n := int(C.av_audio_fifo_size(e.fifo))
+		if n == 0 || n < size && !flush {
+			return nil
+		}
+		if n > size {
+			n = size
+		}
+		if err := p.encodeSamples(e, n, size); err != nil {
+			return err
+		}
+	}
+}
+
+//Encode n samples of the FIFO in a frame of size samples, padded with silence when the encoder needs full frames.
+func (p *Packager) encodeSamples(e *encoder, n, size int) error {
+	frame := avutil.AvFrameAlloc()
+	defer avutil.AvFrameFree(frame)
+	if err := frame.SetChLayout(e.layout); err != nil {
+		return err
+	}
+	f := (*C.AVFrame)(unsafe.Pointer(frame))
+	f.format = C.int(e.ctx.sample_fmt)
+	f.sample_rate = e.ctx.sample_rate
+	f.nb_samples = C.int(n)
+	if n < size && e.ctx.codec.capabilities&C.AV_CODEC_CAP_SMALL_LAST_FRAME == 0 {
+		f.nb_samples = C.int(size)
+	}
+	if ret := C.av_frame_get_buffer(f, 0); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	C.av_samples_set_silence(f.extended_data, 0, f.nb_samples, C.int(e.layout.NbChannels), C.enum_AVSampleFormat(f.format))
+	if ret := C.goav_audio_fifo_read(e.fifo, f, C.int(n)); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	f.pts = C.int64_t(e.pts)
+	e.pts += int64(f.nb_samples)
+	return p.encode(e, f)
+}
+
+//Send a frame to the encoder, nil to flush it, and write the packets it produces.
+func (p *Packager) encode(e *encoder, f *C.AVFrame) error {
+	if ret := C.avcodec_send_frame(e.ctx, f); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	for {
+		ret := C.avcodec_receive_packet(e.ctx, p.pkt)
+		if ret == avutil.AVERROR_EAGAIN || ret == avutil.AVERROR_EOF {
+			return nil
+		}
+		if ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+		p.pkt.stream_index = e.stream.index
+		//The time base of the video encoders is the frame duration, which the muxers need to cut the segments precisely.
+		if p.pkt.duration == 0 && e.ctx.codec_type == C.AVMEDIA_TYPE_VIDEO {
+			p.pkt.duration = 1
+		}
+		C.av_packet_rescale_ts(p.pkt, e.ctx.time_base, e.stream.time_base)
+		if ret := C.av_interleaved_write_frame(p.oc, p.pkt); ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+	}
+}
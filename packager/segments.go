@@ -0,0 +1,193 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package packager
+
+/*
+#cgo pkg-config: libavformat libavutil
+#include <libavformat/avformat.h>
+#include <stdint.h>
+
+extern void goavPackagerOpened(uintptr_t handle, AVIOContext* pb, char* url);
+extern void goavPackagerClosed(uintptr_t handle, AVIOContext* pb);
+
+static int (*goav_packager_default_open)(struct AVFormatContext*, AVIOContext**, const char*, int, AVDictionary**);
+
+static int goav_packager_io_open(struct AVFormatContext* s, AVIOContext** pb, const char* url, int flags, AVDictionary** options)
+{
+	int ret = goav_packager_default_open(s, pb, url, flags, options);
+	if (ret >= 0 && (flags & AVIO_FLAG_WRITE))
+		goavPackagerOpened((uintptr_t)s->opaque, *pb, (char*)url);
+	return ret;
+}
+
+// The hls and dash muxers copy the callbacks and the opaque pointer to the muxers of their segments,
+// which report their files the same way.
+#if LIBAVFORMAT_VERSION_INT >= AV_VERSION_INT(59, 10, 100)
+static int (*goav_packager_default_close)(struct AVFormatContext*, AVIOContext*);
+
+static int goav_packager_io_close(struct AVFormatContext* s, AVIOContext* pb)
+{
+	int ret = goav_packager_default_close(s, pb);
+	goavPackagerClosed((uintptr_t)s->opaque, pb);
+	return ret;
+}
+
+static inline void goav_packager_set_io(AVFormatContext* s, uintptr_t handle)
+{
+	if (!goav_packager_default_open) {
+		goav_packager_default_open = s->io_open;
+		goav_packager_default_close = s->io_close2;
+	}
+	s->opaque = (void*)handle;
+	s->io_open = goav_packager_io_open;
+	s->io_close2 = goav_packager_io_close;
+}
+#else
+static void (*goav_packager_default_close)(struct AVFormatContext*, AVIOContext*);
+
+static void goav_packager_io_close(struct AVFormatContext* s, AVIOContext* pb)
+{
+	goav_packager_default_close(s, pb);
+	goavPackagerClosed((uintptr_t)s->opaque, pb);
+}
+
+static inline void goav_packager_set_io(AVFormatContext* s, uintptr_t handle)
+{
+	if (!goav_packager_default_open) {
+		goav_packager_default_open = s->io_open;
+		goav_packager_default_close = s->io_close;
+	}
+	s->opaque = (void*)handle;
+	s->io_open = goav_packager_io_open;
+	s->io_close = goav_packager_io_close;
+}
+#endif
+*/
+import "C"
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type SegmentKind int
+
+const (
+	//A media segment.
+	MediaSegment SegmentKind = iota
+	//The initialization segment of a rendition with fMP4 segments, written before its first media segment.
+	InitSegment
+	//A playlist of a rendition, the HLS master playlist or the DASH manifest, written again as segments are added.
+	Playlist
+)
+
+func (k SegmentKind) String() string {
+	switch k {
+	case MediaSegment:
+		return "media segment"
+	case InitSegment:
+		return "init segment"
+	case Playlist:
+		return "playlist"
+	}
+	return "SegmentKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+//Segment is a file the packager completed, ready to be uploaded.
+type Segment struct {
+	Kind SegmentKind
+	//Path of the file, in the directory of the options.
+	Path string
+	//Index of the rendition the file belongs to, -1 for the HLS master playlist and the DASH manifest.
+	Rendition int
+}
+
+var (
+	packagers      = make(map[uintptr]*Packager)
+	packagersMutex sync.Mutex
+	nextHandle     uintptr
+)
+
+//Install the callbacks reporting the files of the muxer to the packager.
+func (p *Packager) register() {
+	packagersMutex.Lock()
+	defer packagersMutex.Unlock()
+	nextHandle++
+	p.handle = nextHandle
+	packagers[p.handle] = p
+	C.goav_packager_set_io(p.oc, C.uintptr_t(p.handle))
+}
+
+func (p *Packager) unregister() {
+	packagersMutex.Lock()
+	defer packagersMutex.Unlock()
+	delete(packagers, p.handle)
+}
+
+func packagerByHandle(handle uintptr) *Packager {
+	packagersMutex.Lock()
+	defer packagersMutex.Unlock()
+	return packagers[handle]
+}
+
+func fileOpened(handle, pb uintptr, url string) {
+	if p := packagerByHandle(handle); p != nil {
+		p.files[pb] = url
+	}
+}
+
+func fileClosed(handle, pb uintptr) {
+	if p := packagerByHandle(handle); p != nil {
+		if url, ok := p.files[pb]; ok {
+			delete(p.files, pb)
+			p.closed = append(p.closed, url)
+		}
+	}
+}
+
+//Report the files closed by the muxer, once those written to a temporary file were renamed.
+func (p *Packager) reportSegments() {
+	pending := p.closed[:0]
+	for _, url := range p.closed {
+		path := strings.TrimPrefix(url, "crypto:")
+		if strings.HasSuffix(path, ".tmp") {
+			if _, err := os.Stat(path); err == nil {
+				pending = append(pending, url)
+				continue
+			}
+			path = strings.TrimSuffix(path, ".tmp")
+		}
+		if p.opts.OnSegment != nil {
+			p.opts.OnSegment(p.segment(path))
+		}
+	}
+	p.closed = pending
+}
+
+//Describe the file at path from its name, see the names set by muxerOptions().
+func (p *Packager) segment(path string) Segment {
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	s := Segment{Kind: MediaSegment, Path: path, Rendition: -1}
+	switch {
+	case ext == ".m3u8" || ext == ".mpd":
+		s.Kind = Playlist
+	case strings.HasSuffix(stem, "_init"):
+		s.Kind = InitSegment
+	}
+	if i := strings.IndexByte(stem, '_'); i >= 0 {
+		stem = stem[:i]
+	}
+	if p.opts.Format == DASH {
+		if index, err := strconv.Atoi(stem); err == nil && ext != ".mpd" && index < len(p.streams) {
+			s.Rendition = p.streams[index]
+		}
+	} else if i, ok := p.names[stem]; ok && base != masterPlaylist {
+		s.Rendition = i
+	}
+	return s
+}
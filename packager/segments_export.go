@@ -0,0 +1,20 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package packager
+
+//#cgo pkg-config: libavformat
+//#include <libavformat/avformat.h>
+//#include <stdint.h>
+import "C"
+import "unsafe"
+
+//export goavPackagerOpened
+func goavPackagerOpened(handle C.uintptr_t, pb *C.AVIOContext, url *C.char) {
+	fileOpened(uintptr(handle), uintptr(unsafe.Pointer(pb)), C.GoString(url))
+}
+
+//export goavPackagerClosed
+func goavPackagerClosed(handle C.uintptr_t, pb *C.AVIOContext) {
+	fileClosed(uintptr(handle), uintptr(unsafe.Pointer(pb)))
+}
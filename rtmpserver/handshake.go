@@ -0,0 +1,286 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package rtmpserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	//C0, C1 and C2, the handshake sent by the client before its first chunk.
+	handshakeSize = 1 + 1536 + 1536
+	//Largest message expected before publishing, commands being a few hundred bytes.
+	maxCommandSize = 64 << 10
+
+	msgSetChunkSize = 1
+	msgAMF3Command  = 17
+	msgAMF0Command  = 20
+)
+
+var errAMF = errors.New("Invalid AMF0 data in RTMP command")
+
+//publishSniffer follows what an RTMP client sends until it publishes, to find the app it connected to
+//and the stream it publishes, without altering the connection.
+type publishSniffer struct {
+	buf       []byte
+	skip      int
+	chunkSize int
+	streams   map[uint32]*chunkStream
+	app, key  string
+	done      bool
+}
+
+type chunkStream struct {
+	length   int
+	typeID   byte
+	extended bool
+	payload  []byte
+}
+
+func newPublishSniffer() *publishSniffer {
+	return &publishSniffer{skip: handshakeSize, chunkSize: 128, streams: make(map[uint32]*chunkStream)}
+}
+
+//Feed the bytes sent by the client, returning true once it published.
+func (p *publishSniffer) feed(b []byte) (bool, error) {
+	if p.done {
+		return true, nil
+	}
+	if p.skip > 0 {
+		n := len(b)
+		if n > p.skip {
+			n = p.skip
+		}
+		p.skip -= n
+		b = b[n:]
+	}
+	p.buf = append(p.buf, b...)
+	for !p.done {
+		n, err := p.chunk(p.buf)
+		if err != nil {
+			return false, err
+		}
+		if n == 0 {
+			break
+		}
+		p.buf = p.buf[n:]
+	}
+	if p.done {
+		p.buf, p.streams = nil, nil
+	}
+	return p.done, nil
+}
+
+//Parse the chunk at the start of b, returning its size, 0 when it is not complete yet.
+func (p *publishSniffer) chunk(b []byte) (int, error) {
+	if len(b) < 1 {
+		return 0, nil
+	}
+	format := b[0] >> 6
+	id := uint32(b[0] & 0x3f)
+	n := 1
+	switch id {
+	case 0:
+		if len(b) < 2 {
+			return 0, nil
+		}
+		id, n = 64+uint32(b[1]), 2
+	case 1:
+		if len(b) < 3 {
+			return 0, nil
+		}
+		id, n = 64+uint32(b[1])+uint32(b[2])<<8, 3
+	}
+	cs := p.streams[id]
+	if cs == nil {
+		if format != 0 {
+			return 0, fmt.Errorf("RTMP chunk stream %d starts without a full header", id)
+		}
+		cs = &chunkStream{}
+		p.streams[id] = cs
+	}
+	headerSize := [4]int{11, 7, 3, 0}[format]
+	if len(b) < n+headerSize {
+		return 0, nil
+	}
+	header := b[n : n+headerSize]
+	n += headerSize
+	length, typeID, extended := cs.length, cs.typeID, cs.extended
+	if format < 3 {
+		extended = header[0] == 0xff && header[1] == 0xff && header[2] == 0xff
+	}
+	if format < 2 {
+		if len(cs.payload) > 0 {
+			return 0, fmt.Errorf("RTMP message on chunk stream %d interrupted by another one", id)
+		}
+		length = int(header[3])<<16 | int(header[4])<<8 | int(header[5])
+		typeID = header[6]
+		if length > maxCommandSize {
+			return 0, fmt.Errorf("RTMP message of %d bytes before publishing", length)
+		}
+	}
+	if extended {
+		n += 4
+	}
+	size := length - len(cs.payload)
+	if size > p.chunkSize {
+		size = p.chunkSize
+	}
+	if len(b) < n+size {
+		return 0, nil
+	}
+	cs.length, cs.typeID, cs.extended = length, typeID, extended
+	cs.payload = append(cs.payload, b[n:n+size]...)
+	n += size
+	if len(cs.payload) == cs.length {
+		msg := cs.payload
+		cs.payload = nil
+		if err := p.message(cs.typeID, msg); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (p *publishSniffer) message(typeID byte, b []byte) error {
+	switch typeID {
+	case msgSetChunkSize:
+		if len(b) < 4 {
+			return errors.New("Invalid RTMP chunk size message")
+		}
+		size := int(binary.BigEndian.Uint32(b) & 0x7fffffff)
+		if size == 0 {
+			return errors.New("Invalid RTMP chunk size 0")
+		}
+		p.chunkSize = size
+	case msgAMF3Command:
+		if len(b) > 0 {
+			return p.command(b[1:])
+		}
+	case msgAMF0Command:
+		return p.command(b)
+	}
+	return nil
+}
+
+func (p *publishSniffer) command(b []byte) error {
+	r := &amfReader{b: b}
+	name, _, err := r.value()
+	if err != nil {
+		return err
+	}
+	//Transaction id.
+	if _, _, err := r.value(); err != nil {
+		return err
+	}
+	switch name {
+	case "connect":
+		_, props, err := r.value()
+		if err != nil {
+			return err
+		}
+		p.app = props["app"]
+	case "publish":
+		//Command object, always null.
+		if _, _, err := r.value(); err != nil {
+			return err
+		}
+		if p.key, _, err = r.value(); err != nil {
+			return err
+		}
+		p.done = true
+	case "play":
+		return errors.New("RTMP client asked to play a stream, only publishing is supported")
+	}
+	return nil
+}
+
+type amfReader struct {
+	b []byte
+}
+
+func (r *amfReader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.b) < n {
+		return nil, errAMF
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v, nil
+}
+
+func (r *amfReader) string(lengthSize int) (string, error) {
+	l, err := r.next(lengthSize)
+	if err != nil {
+		return "", err
+	}
+	n := int(binary.BigEndian.Uint16(l))
+	if lengthSize == 4 {
+		n = int(binary.BigEndian.Uint32(l))
+	}
+	s, err := r.next(n)
+	return string(s), err
+}
+
+//Read a value, returning its text when it is a string and its string properties when it is an object.
+func (r *amfReader) value() (string, map[string]string, error) {
+	t, err := r.next(1)
+	if err != nil {
+		return "", nil, err
+	}
+	switch t[0] {
+	case 0x00: //number
+		_, err = r.next(8)
+	case 0x01: //boolean
+		_, err = r.next(1)
+	case 0x02: //string
+		s, err := r.string(2)
+		return s, nil, err
+	case 0x03: //object
+		props, err := r.properties()
+		return "", props, err
+	case 0x05, 0x06: //null, undefined
+	case 0x08: //ECMA array
+		if _, err = r.next(4); err == nil {
+			props, err := r.properties()
+			return "", props, err
+		}
+	case 0x0a: //strict array
+		var l []byte
+		if l, err = r.next(4); err == nil {
+			for i := binary.BigEndian.Uint32(l); i > 0 && err == nil; i-- {
+				_, _, err = r.value()
+			}
+		}
+	case 0x0b: //date
+		_, err = r.next(10)
+	case 0x0c: //long string
+		s, err := r.string(4)
+		return s, nil, err
+	default:
+		err = errAMF
+	}
+	return "", nil, err
+}
+
+func (r *amfReader) properties() (map[string]string, error) {
+	props := make(map[string]string)
+	for {
+		key, err := r.string(2)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			end, err := r.next(1)
+			if err != nil || end[0] != 0x09 {
+				return nil, errAMF
+			}
+			return props, nil
+		}
+		if props[key], _, err = r.value(); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package rtmpserver
+
+/*
+#cgo pkg-config: libavformat libavcodec libavutil
+#include <libavformat/avformat.h>
+#include <libavcodec/avcodec.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+var networkOnce sync.Once
+
+func networkInit() {
+	networkOnce.Do(func() {
+		C.avformat_network_init()
+	})
+}
+
+//Publish the audio and video streams of input, a file or URL libavformat can open, to the RTMP url,
+//such as "rtmp://127.0.0.1:1935/live/key", until input ends or ctx is done.
+//When realtime is set, packets are sent at the pace of their timestamps, as a live encoder does.
+//The codecs of the streams must be supported by FLV, such as H.264 and AAC.
+func Publish(ctx context.Context, url, input string, realtime bool) error {
+	networkInit()
+	intr := newInterrupter()
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			intr.interrupt()
+		case <-stop:
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+		intr.free()
+	}()
+	err := publish(ctx, intr, url, input, realtime)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func publish(ctx context.Context, intr *interrupter, url, input string, realtime bool) error {
+	cinput, curl, cflv := C.CString(input), C.CString(url), C.CString("flv")
+	defer C.free(unsafe.Pointer(cinput))
+	defer C.free(unsafe.Pointer(curl))
+	defer C.free(unsafe.Pointer(cflv))
+
+	ic := C.avformat_alloc_context()
+	if ic == nil {
+		return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	intr.attach(ic)
+	if ret := C.avformat_open_input(&ic, cinput, nil, nil); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	defer C.avformat_close_input(&ic)
+	if ret := C.avformat_find_stream_info(ic, nil); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+
+	var oc *C.AVFormatContext
+	if ret := C.avformat_alloc_output_context2(&oc, nil, cflv, curl); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	defer C.avformat_free_context(oc)
+	intr.attach(oc)
+	inputs := (*[1 << 16]*C.AVStream)(unsafe.Pointer(ic.streams))[:ic.nb_streams:ic.nb_streams]
+	outputs := make([]*C.AVStream, len(inputs))
+	for i, st := range inputs {
+		if st.codecpar.codec_type != C.AVMEDIA_TYPE_VIDEO && st.codecpar.codec_type != C.AVMEDIA_TYPE_AUDIO {
+			continue
+		}
+		out := C.avformat_new_stream(oc, nil)
+		if out == nil {
+			return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+		}
+		if ret := C.avcodec_parameters_copy(out.codecpar, st.codecpar); ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+		out.codecpar.codec_tag = 0
+		out.time_base = st.time_base
+		outputs[i] = out
+	}
+	if oc.nb_streams == 0 {
+		return errors.New("No audio or video stream to publish")
+	}
+
+	if ret := C.avio_open2(&oc.pb, curl, C.AVIO_FLAG_WRITE, &oc.interrupt_callback, nil); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	defer C.avio_closep(&oc.pb)
+	if ret := C.avformat_write_header(oc, nil); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+
+	pkt := C.av_packet_alloc()
+	defer C.av_packet_free(&pkt)
+	start := time.Now()
+	first := int64(avutil.AV_NOPTS_VALUE)
+	for {
+		ret := C.av_read_frame(ic, pkt)
+		if ret == avutil.AVERROR_EOF {
+			break
+		}
+		if ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+		in := inputs[pkt.stream_index]
+		out := outputs[pkt.stream_index]
+		if out == nil {
+			C.av_packet_unref(pkt)
+			continue
+		}
+		if realtime && int64(pkt.dts) != avutil.AV_NOPTS_VALUE {
+			dts := int64(C.av_rescale_q(pkt.dts, in.time_base, C.AVRational{num: 1, den: C.AV_TIME_BASE}))
+			if first == avutil.AV_NOPTS_VALUE {
+				first = dts
+			}
+			select {
+			case <-time.After(time.Until(start.Add(time.Duration(dts-first) * time.Microsecond))):
+			case <-ctx.Done():
+				C.av_packet_unref(pkt)
+				return ctx.Err()
+			}
+		}
+		pkt.stream_index = out.index
+		C.av_packet_rescale_ts(pkt, in.time_base, out.time_base)
+		pkt.pos = -1
+		if ret := C.av_interleaved_write_frame(oc, pkt); ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+	}
+	if ret := C.av_write_trailer(oc); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
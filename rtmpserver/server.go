@@ -0,0 +1,283 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+// Package rtmpserver accepts RTMP publishers and hands each of their sessions, demuxed by libavformat, to a handler.
+// The rtmp protocol of libavformat listens for a single publisher, and only warns when it publishes another app or
+// stream than expected, so the server accepts the connections itself, checks what they publish, and relays each one
+// to a demuxer of its own listening on the loopback interface.
+package rtmpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//ErrServerClosed is returned by Serve() and ListenAndServe() after Shutdown() or Close().
+var ErrServerClosed = errors.New("Server closed")
+
+//Handler handles a session until its publisher stops or ctx is done, which it must then return upon.
+//The session, and its Input, are freed when it returns.
+type Handler func(ctx context.Context, s *Session) error
+
+type ServerOptions struct {
+	//Address to listen on with ListenAndServe(), ":1935" by default.
+	Addr string
+	//App and stream key publishers must publish to, any when empty.
+	App, StreamKey string
+	//Maximum number of concurrent sessions, unlimited when 0.
+	MaxSessions int
+	//Time a publisher has to publish and send enough for its streams to be found, 10 seconds by default.
+	HandshakeTimeout time.Duration
+	//Options of the rtmp protocol and the flv demuxer, such as "rtmp_buffer", or "analyzeduration" and "fpsprobesize"
+	//which shorten the time taken to find the streams.
+	Options map[string]string
+	//Called when a connection is rejected before its session started, and when a session ends,
+	//with the error of its handler.
+	OnReject     func(addr net.Addr, err error)
+	OnSessionEnd func(s *Session, err error)
+}
+
+//Metrics are the counters of a server since it was created.
+type Metrics struct {
+	//Connections accepted, and those rejected before their session started, for publishing another app or stream,
+	//playing, exceeding MaxSessions or failing the handshake.
+	Connections, Rejected int64
+	//Sessions started, those still running, and those whose handler failed.
+	Sessions, ActiveSessions, FailedSessions int64
+	//Bytes received from and sent to publishers.
+	BytesReceived, BytesSent int64
+}
+
+type counters struct {
+	connections, rejected, sessions, activeSessions, failedSessions, bytesReceived, bytesSent int64
+}
+
+type Server struct {
+	counters counters
+
+	opts    ServerOptions
+	handler Handler
+
+	mu       sync.Mutex
+	listener net.Listener
+	cancel   context.CancelFunc
+	closed   bool
+	sessions map[*Session]struct{}
+	pending  int
+	nextID   uint64
+	conns    sync.WaitGroup
+}
+
+func NewServer(opts ServerOptions, handler Handler) *Server {
+	if opts.Addr == "" {
+		opts.Addr = ":1935"
+	}
+	if opts.HandshakeTimeout <= 0 {
+		opts.HandshakeTimeout = 10 * time.Second
+	}
+	networkInit()
+	return &Server{opts: opts, handler: handler, sessions: make(map[*Session]struct{})}
+}
+
+//Listen on the address of the options and serve publishers, as Serve() does.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+//Accept publishers on l and run the handler for each of their sessions, until Shutdown() or Close() is called,
+//returning ErrServerClosed, or until ctx is done, cancelling the sessions and returning once they ended.
+//l is closed when returning.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	if s.closed || s.listener != nil {
+		s.mu.Unlock()
+		cancel()
+		l.Close()
+		if s.closed {
+			return ErrServerClosed
+		}
+		return errors.New("Server already serving")
+	}
+	s.listener, s.cancel = l, cancel
+	s.mu.Unlock()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.Close()
+		case <-stop:
+		}
+	}()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			l.Close()
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				s.mu.Lock()
+				s.closed = true
+				s.mu.Unlock()
+				s.conns.Wait()
+				return ctxErr
+			}
+			return err
+		}
+		atomic.AddInt64(&s.counters.connections, 1)
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return ErrServerClosed
+		}
+		s.conns.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.conns.Done()
+			s.serveConn(ctx, c)
+		}()
+	}
+}
+
+//Stop accepting publishers and wait for the sessions to end, until ctx is done, when they are cancelled as Close() does.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	l := s.listener
+	s.mu.Unlock()
+	if l != nil {
+		l.Close()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Close()
+		return ctx.Err()
+	}
+}
+
+//Stop accepting publishers, cancel the sessions and wait for them to end.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	l, cancel := s.listener, s.cancel
+	s.mu.Unlock()
+	if l != nil {
+		l.Close()
+	}
+	if cancel != nil {
+		cancel()
+	}
+	s.conns.Wait()
+	return nil
+}
+
+//Return the address the server listens on, nil when it is not serving.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+func (s *Server) Metrics() Metrics {
+	return Metrics{
+		Connections:    atomic.LoadInt64(&s.counters.connections),
+		Rejected:       atomic.LoadInt64(&s.counters.rejected),
+		Sessions:       atomic.LoadInt64(&s.counters.sessions),
+		ActiveSessions: atomic.LoadInt64(&s.counters.activeSessions),
+		FailedSessions: atomic.LoadInt64(&s.counters.failedSessions),
+		BytesReceived:  atomic.LoadInt64(&s.counters.bytesReceived),
+		BytesSent:      atomic.LoadInt64(&s.counters.bytesSent),
+	}
+}
+
+//Return the sessions running.
+func (s *Server) Sessions() []*Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) serveConn(ctx context.Context, c net.Conn) {
+	s.mu.Lock()
+	full := s.opts.MaxSessions > 0 && len(s.sessions)+s.pending >= s.opts.MaxSessions
+	if !full {
+		s.pending++
+	}
+	s.nextID++
+	id := s.nextID
+	s.mu.Unlock()
+	if full {
+		c.Close()
+		s.reject(c.RemoteAddr(), errors.New("Too many sessions"))
+		return
+	}
+
+	sess := &Session{ID: id, RemoteAddr: c.RemoteAddr(), server: s, client: c, intr: newInterrupter()}
+	sess.ctx, sess.cancel = context.WithCancel(ctx)
+	defer sess.close()
+	err := sess.open(s.opts.HandshakeTimeout)
+	sess.Started = time.Now()
+	s.mu.Lock()
+	s.pending--
+	if err == nil {
+		s.sessions[sess] = struct{}{}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.reject(c.RemoteAddr(), err)
+		return
+	}
+
+	atomic.AddInt64(&s.counters.sessions, 1)
+	atomic.AddInt64(&s.counters.activeSessions, 1)
+	err = s.handler(sess.ctx, sess)
+	if err != nil && sess.ctx.Err() == nil {
+		atomic.AddInt64(&s.counters.failedSessions, 1)
+	}
+	s.mu.Lock()
+	delete(s.sessions, sess)
+	s.mu.Unlock()
+	atomic.AddInt64(&s.counters.activeSessions, -1)
+	if s.opts.OnSessionEnd != nil {
+		s.opts.OnSessionEnd(sess, err)
+	}
+}
+
+func (s *Server) reject(addr net.Addr, err error) {
+	atomic.AddInt64(&s.counters.rejected, 1)
+	if s.opts.OnReject != nil {
+		s.opts.OnReject(addr, err)
+	}
+}
@@ -0,0 +1,237 @@
+package rtmpserver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avfilter"
+	"github.com/alon-ne/goav/avutil"
+)
+
+//Write an flv file of a sine wave generated by avfilter, in tags of 16 bit PCM samples.
+func writeSine(t *testing.T, duration time.Duration) string {
+	t.Helper()
+	const rate = 44100
+	s16 := avcodec.AvSampleFormat(avcodec.AV_SAMPLE_FMT_S16)
+	src, err := avfilter.Sine(440, avfilter.AudioSourceSpec{SampleRate: rate, ChannelLayout: avutil.DefaultChannelLayout(1),
+		Duration: duration, SampleFormat: &s16})
+	if err != nil {
+		t.Fatalf("Sine() failed: %#v", err)
+	}
+	defer src.Free()
+	//Header of an flv file with audio only, followed by the size of the previous tag, none.
+	flv := []byte{'F', 'L', 'V', 1, 4, 0, 0, 0, 9, 0, 0, 0, 0}
+	samples := 0
+	for {
+		f, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() failed: %#v", err)
+		}
+		//Data() points to the array of the planes.
+		data := unsafe.Slice(*(**uint8)(unsafe.Pointer(avutil.Data(f))), 2*f.NbSamples())
+		ms := uint32(int64(samples) * 1000 / rate)
+		tag := []byte{8, 0, 0, 0, 0, 0, 0, byte(ms >> 24), 0, 0, 0,
+			//Little endian PCM at 44 kHz, 16 bit, mono.
+			3<<4 | 3<<2 | 1<<1}
+		size := uint32(len(data) + 1)
+		tag[1], tag[2], tag[3] = byte(size>>16), byte(size>>8), byte(size)
+		tag[4], tag[5], tag[6] = byte(ms>>16), byte(ms>>8), byte(ms)
+		flv = append(append(flv, tag...), data...)
+		flv = binary.BigEndian.AppendUint32(flv, uint32(11+len(data)+1))
+		samples += f.NbSamples()
+		avutil.AvFrameFree(f)
+	}
+	path := filepath.Join(t.TempDir(), "sine.flv")
+	if err := os.WriteFile(path, flv, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+//Serve on a port of the loopback interface, returning the channel the result of Serve() is sent to.
+func serve(t *testing.T, ctx context.Context, s *Server) <-chan error {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, l)
+	}()
+	t.Cleanup(func() {
+		s.Close()
+	})
+	for s.Addr() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	return served
+}
+
+func countPackets(ctx context.Context, s *Session) (int, error) {
+	pkt := avcodec.AvPacketAlloc()
+	defer avcodec.AvPacketFree(pkt)
+	packets := 0
+	for {
+		err := s.ReadPacket(pkt)
+		if err == io.EOF {
+			return packets, nil
+		}
+		if err != nil {
+			return packets, err
+		}
+		packets++
+		pkt.AvPacketUnref()
+	}
+}
+
+func TestServeSession(t *testing.T) {
+	input := writeSine(t, 2*time.Second)
+	type result struct {
+		app, key string
+		packets  int
+		err      error
+	}
+	results := make(chan result, 1)
+	ended := make(chan error, 1)
+	s := NewServer(ServerOptions{App: "live", StreamKey: "key", OnSessionEnd: func(s *Session, err error) {
+		ended <- err
+	}}, func(ctx context.Context, s *Session) error {
+		packets, err := countPackets(ctx, s)
+		results <- result{s.App, s.StreamKey, packets, err}
+		return err
+	})
+	served := serve(t, context.Background(), s)
+
+	url := fmt.Sprintf("rtmp://%s/live/key", s.Addr())
+	if err := Publish(context.Background(), url, input, false); err != nil {
+		t.Fatalf("Publish() failed: %#v", err)
+	}
+	r := <-results
+	if r.err != nil {
+		t.Fatalf("ReadPacket() failed: %#v", r.err)
+	}
+	if r.app != "live" || r.key != "key" {
+		t.Errorf("Published %q/%q, want live/key", r.app, r.key)
+	}
+	if r.packets == 0 {
+		t.Error("No packet read")
+	}
+	if err := <-ended; err != nil {
+		t.Errorf("Session ended with %#v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() failed: %#v", err)
+	}
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("Serve() returned %#v, want ErrServerClosed", err)
+	}
+	m := s.Metrics()
+	if m.Connections != 1 || m.Rejected != 0 || m.Sessions != 1 || m.ActiveSessions != 0 || m.FailedSessions != 0 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+	if m.BytesReceived == 0 || m.BytesSent == 0 {
+		t.Errorf("No bytes counted in %+v", m)
+	}
+}
+
+func TestServeRejectsStreamKey(t *testing.T) {
+	input := writeSine(t, time.Second)
+	rejected := make(chan error, 1)
+	s := NewServer(ServerOptions{App: "live", StreamKey: "key", OnReject: func(addr net.Addr, err error) {
+		rejected <- err
+	}}, func(ctx context.Context, s *Session) error {
+		t.Errorf("Session started for %q/%q", s.App, s.StreamKey)
+		return nil
+	})
+	serve(t, context.Background(), s)
+
+	url := fmt.Sprintf("rtmp://%s/live/other", s.Addr())
+	if err := Publish(context.Background(), url, input, false); err == nil {
+		t.Error("Publish() with the wrong stream key succeeded")
+	}
+	select {
+	case err := <-rejected:
+		if err == nil {
+			t.Error("Rejected with no error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publisher not rejected")
+	}
+	if m := s.Metrics(); m.Connections != 1 || m.Rejected != 1 || m.Sessions != 0 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+}
+
+func TestServeCancel(t *testing.T) {
+	input := writeSine(t, 30*time.Second)
+	started := make(chan struct{})
+	var handled error
+	var mu sync.Mutex
+	s := NewServer(ServerOptions{Options: map[string]string{"analyzeduration": "100000"}}, func(ctx context.Context, s *Session) error {
+		close(started)
+		_, err := countPackets(ctx, s)
+		mu.Lock()
+		handled = err
+		mu.Unlock()
+		return err
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := serve(t, ctx, s)
+
+	pctx, pcancel := context.WithCancel(context.Background())
+	defer pcancel()
+	published := make(chan error, 1)
+	go func() {
+		published <- Publish(pctx, fmt.Sprintf("rtmp://%s/live/stream", s.Addr()), input, true)
+	}()
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Session not started")
+	}
+	if n := len(s.Sessions()); n != 1 {
+		t.Errorf("%d sessions running, want 1", n)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Serve() returned %#v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve() did not return once cancelled")
+	}
+	mu.Lock()
+	if !errors.Is(handled, context.Canceled) {
+		t.Errorf("ReadPacket() returned %#v, want context.Canceled", handled)
+	}
+	mu.Unlock()
+	if m := s.Metrics(); m.Sessions != 1 || m.ActiveSessions != 0 || m.FailedSessions != 0 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+
+	pcancel()
+	if err := <-published; err == nil {
+		t.Error("Publish() of a cancelled session succeeded")
+	}
+}
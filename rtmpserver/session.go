@@ -0,0 +1,407 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package rtmpserver
+
+/*
+#cgo pkg-config: libavformat libavcodec libavutil
+#include <libavformat/avformat.h>
+#include <stdlib.h>
+
+static int goav_interrupted(void* flag)
+{
+	return __atomic_load_n((int*)flag, __ATOMIC_SEQ_CST);
+}
+
+static inline void goav_set_interrupt(AVFormatContext* s, int* flag)
+{
+	s->interrupt_callback.callback = goav_interrupted;
+	s->interrupt_callback.opaque = flag;
+}
+
+static inline void goav_interrupt(int* flag)
+{
+	__atomic_store_n(flag, 1, __ATOMIC_SEQ_CST);
+}
+*/
+import "C"
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avformat"
+	"github.com/alon-ne/goav/avutil"
+)
+
+//interrupter aborts the blocking calls of the format contexts it is attached to once triggered.
+type interrupter struct {
+	flag *C.int
+}
+
+func newInterrupter() *interrupter {
+	return &interrupter{flag: (*C.int)(C.calloc(1, C.sizeof_int))}
+}
+
+func (i *interrupter) attach(s *C.AVFormatContext) {
+	C.goav_set_interrupt(s, i.flag)
+}
+
+func (i *interrupter) interrupt() {
+	C.goav_interrupt(i.flag)
+}
+
+//Free the flag, once the contexts it is attached to are freed.
+func (i *interrupter) free() {
+	C.free(unsafe.Pointer(i.flag))
+	i.flag = nil
+}
+
+//Session is a publisher connected to the server, whose streams are read from Input.
+type Session struct {
+	ID         uint64
+	RemoteAddr net.Addr
+	//App the publisher connected to and key of the stream it publishes.
+	App, StreamKey string
+	//Demuxer of the published streams, whose stream info was already found.
+	Input   *avformat.Context
+	Started time.Time
+
+	received, sent, packets int64
+	disconnected            int32
+
+	server          *Server
+	ctx             context.Context
+	cancel          context.CancelFunc
+	client, demuxer net.Conn
+	intr            *interrupter
+	mu              sync.Mutex
+	stopped         bool
+	relays          sync.WaitGroup
+}
+
+//SessionStats are the metrics of a session.
+type SessionStats struct {
+	//Bytes received from and sent to the publisher.
+	BytesReceived, BytesSent int64
+	//Packets read with ReadPacket().
+	Packets  int64
+	Duration time.Duration
+}
+
+//Read the next packet of the published streams into pkt, to be unreferenced by the caller.
+//io.EOF is returned once the publisher stopped, and the error of the context once the session is cancelled.
+func (s *Session) ReadPacket(pkt *avcodec.Packet) error {
+	ret := C.av_read_frame((*C.AVFormatContext)(unsafe.Pointer(s.Input)), (*C.AVPacket)(unsafe.Pointer(pkt)))
+	if ret < 0 {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		if ret == avutil.AVERROR_EOF || atomic.LoadInt32(&s.disconnected) != 0 {
+			return io.EOF
+		}
+		return &avutil.Error{Num: int(ret)}
+	}
+	atomic.AddInt64(&s.packets, 1)
+	return nil
+}
+
+func (s *Session) Stats() SessionStats {
+	return SessionStats{
+		BytesReceived: atomic.LoadInt64(&s.received),
+		BytesSent:     atomic.LoadInt64(&s.sent),
+		Packets:       atomic.LoadInt64(&s.packets),
+		Duration:      time.Since(s.Started),
+	}
+}
+
+//Abort the demuxer and the connections, from any goroutine.
+func (s *Session) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	s.intr.interrupt()
+	s.client.Close()
+	if s.demuxer != nil {
+		s.demuxer.Close()
+	}
+}
+
+//Set the connection to the demuxer, unless the session was stopped meanwhile.
+func (s *Session) setDemuxer(d net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		d.Close()
+		return false
+	}
+	s.demuxer = d
+	return true
+}
+
+func (s *Session) close() {
+	s.cancel()
+	s.stop()
+	s.relays.Wait()
+	s.closeInput()
+	s.intr.free()
+}
+
+func (s *Session) closeInput() {
+	if s.Input != nil {
+		ctx := (*C.AVFormatContext)(unsafe.Pointer(s.Input))
+		C.avformat_close_input(&ctx)
+		s.Input = nil
+	}
+}
+
+//Relay the publisher to a demuxer listening on the loopback interface,
+//until it published to the expected app and stream and the demuxer found its streams.
+func (s *Session) open(timeout time.Duration) error {
+	hctx, hcancel := context.WithTimeout(s.ctx, timeout)
+	defer hcancel()
+	started := make(chan struct{})
+	go func() {
+		select {
+		case <-hctx.Done():
+		case <-started:
+		}
+		select {
+		case <-started:
+			<-s.ctx.Done()
+		default:
+		}
+		s.stop()
+	}()
+
+	opened, err := s.connectDemuxer(hctx, timeout)
+	if err != nil {
+		return err
+	}
+
+	published := make(chan error, 1)
+	s.relays.Add(2)
+	go s.relayFromClient(published)
+	go s.relayToClient()
+
+	if err := <-opened; err != nil {
+		if hctx.Err() != nil {
+			return s.handshakeError(hctx)
+		}
+		select {
+		case perr := <-published:
+			if perr != nil {
+				return perr
+			}
+		default:
+		}
+		return err
+	}
+	if err := <-published; err != nil {
+		return err
+	}
+	if hctx.Err() != nil {
+		return s.handshakeError(hctx)
+	}
+	close(started)
+	return nil
+}
+
+//Number of ports a demuxer is started on before giving up, see connectDemuxer().
+const demuxerAttempts = 3
+
+//Start a demuxer listening on a free port of the loopback interface and connect to it,
+//returning the channel its result is sent to once the publisher was relayed to it.
+//The port is only known to be free when it is picked, so that another process may bind it before the demuxer does,
+//or connect to the demuxer before the session does: the demuxer is then started again on another port.
+//Should the session connect to the other process, which it cannot tell, the demuxer fails and so does the handshake.
+func (s *Session) connectDemuxer(hctx context.Context, timeout time.Duration) (<-chan error, error) {
+	var err error
+	for attempt := 0; attempt < demuxerAttempts; attempt++ {
+		var port int
+		if port, err = loopbackPort(); err != nil {
+			return nil, err
+		}
+		opened := make(chan error, 1)
+		go func() {
+			opened <- s.openInput(s.demuxerURL(port), timeout)
+		}()
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		var dialer net.Dialer
+		for dialing := true; dialing; {
+			d, derr := dialer.DialContext(hctx, "tcp", addr)
+			if derr == nil {
+				if !s.setDemuxer(d) {
+					<-opened
+					return nil, s.handshakeError(hctx)
+				}
+				return opened, nil
+			}
+			select {
+			case err = <-opened:
+				if err == nil {
+					s.closeInput()
+					err = fmt.Errorf("Demuxer accepted another connection on %s", addr)
+				} else if e, ok := err.(*avutil.Error); !ok || e.Num != -int(syscall.EADDRINUSE) {
+					return nil, err
+				}
+				dialing = false
+			case <-hctx.Done():
+				s.stop()
+				<-opened
+				return nil, s.handshakeError(hctx)
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+		if hctx.Err() != nil {
+			return nil, s.handshakeError(hctx)
+		}
+	}
+	return nil, err
+}
+
+//Return the url of a demuxer listening on port for the app and stream of the options.
+func (s *Session) demuxerURL(port int) string {
+	app, key := s.server.opts.App, s.server.opts.StreamKey
+	if app == "" {
+		app = "live"
+	}
+	if key == "" {
+		key = "stream"
+	}
+	return fmt.Sprintf("rtmp://127.0.0.1:%d/%s/%s", port, app, key)
+}
+
+func (s *Session) handshakeError(hctx context.Context) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("Handshake with %s timed out", s.RemoteAddr)
+}
+
+//Open the demuxer listening at url, which fails when the publisher is rejected or the session stopped.
+func (s *Session) openInput(url string, timeout time.Duration) error {
+	ctx := C.avformat_alloc_context()
+	if ctx == nil {
+		return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	s.intr.attach(ctx)
+	opts := map[string]string{"listen": "1", "timeout": strconv.Itoa(int((timeout + time.Second - 1) / time.Second))}
+	for k, v := range s.server.opts.Options {
+		opts[k] = v
+	}
+	var d *avutil.Dictionary
+	for key, value := range opts {
+		avutil.AvDictSet(&d, key, value, 0)
+	}
+	defer avutil.AvDictFree(&d)
+	curl, cflv := C.CString(url), C.CString("flv")
+	defer C.free(unsafe.Pointer(curl))
+	defer C.free(unsafe.Pointer(cflv))
+	if ret := C.avformat_open_input(&ctx, curl, C.av_find_input_format(cflv), (**C.AVDictionary)(unsafe.Pointer(&d))); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	empty := C.CString("")
+	defer C.free(unsafe.Pointer(empty))
+	if e := C.av_dict_get((*C.AVDictionary)(unsafe.Pointer(d)), empty, nil, C.AV_DICT_IGNORE_SUFFIX); e != nil {
+		C.avformat_close_input(&ctx)
+		return fmt.Errorf("Unknown demuxer option %q", C.GoString(e.key))
+	}
+	if ret := C.avformat_find_stream_info(ctx, nil); ret < 0 {
+		C.avformat_close_input(&ctx)
+		return &avutil.Error{Num: int(ret)}
+	}
+	s.Input = (*avformat.Context)(unsafe.Pointer(ctx))
+	return nil
+}
+
+//Copy what the publisher sends to the demuxer, holding back its publish command until it is accepted.
+//Once the publisher disconnected, the demuxer reads the end of the stream, while what it still writes is discarded.
+func (s *Session) relayFromClient(published chan<- error) {
+	defer s.relays.Done()
+	sniffer := newPublishSniffer()
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := s.client.Read(buf)
+		if n > 0 {
+			if !sniffer.done {
+				done, serr := sniffer.feed(buf[:n])
+				if serr == nil && done {
+					serr = s.accept(sniffer.app, sniffer.key)
+				}
+				if serr != nil {
+					published <- serr
+					s.stop()
+					return
+				}
+				if done {
+					published <- nil
+				}
+			}
+			if _, err := s.demuxer.Write(buf[:n]); err != nil {
+				return
+			}
+			atomic.AddInt64(&s.received, int64(n))
+			atomic.AddInt64(&s.server.counters.bytesReceived, int64(n))
+		}
+		if err != nil {
+			atomic.StoreInt32(&s.disconnected, 1)
+			if c, ok := s.demuxer.(*net.TCPConn); ok {
+				c.CloseWrite()
+			} else {
+				s.demuxer.Close()
+			}
+			return
+		}
+	}
+}
+
+func (s *Session) relayToClient() {
+	defer s.relays.Done()
+	defer s.client.Close()
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := s.demuxer.Read(buf)
+		if n > 0 && atomic.LoadInt32(&s.disconnected) == 0 {
+			if _, err := s.client.Write(buf[:n]); err == nil {
+				atomic.AddInt64(&s.sent, int64(n))
+				atomic.AddInt64(&s.server.counters.bytesSent, int64(n))
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Session) accept(app, key string) error {
+	if want := s.server.opts.App; want != "" && app != want {
+		return fmt.Errorf("Rejected %s publishing to app %q", s.RemoteAddr, app)
+	}
+	if want := s.server.opts.StreamKey; want != "" && key != want {
+		return fmt.Errorf("Rejected %s publishing with stream key %q", s.RemoteAddr, key)
+	}
+	s.App, s.StreamKey = app, key
+	return nil
+}
+
+//Return a free port of the loopback interface for a demuxer to listen on.
+func loopbackPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
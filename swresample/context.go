@@ -55,12 +55,13 @@ func (s *Context) SwrSetCompensation(sd, cd int) int {
 }
 
 //Set a customized input channel mapping.
-func (s *Context) SwrSetChannelMapping(cm *int) int {
+//The context keeps the pointer, so cm must stay valid until the context is freed, e.g. allocated with AvMalloc().
+func (s *Context) SwrSetChannelMapping(cm *int32) int {
 	return int(C.swr_set_channel_mapping((*C.struct_SwrContext)(s), (*C.int)(unsafe.Pointer(cm))))
 }
 
-//Set a customized remix matrix.
-func (s *Context) SwrSetMatrix(m *int, t int) int {
+//Set a customized remix matrix, m[i + t * o] being the weight of input channel i in output channel o.
+func (s *Context) SwrSetMatrix(m *float64, t int) int {
 	return int(C.swr_set_matrix((*C.struct_SwrContext)(s), (*C.double)(unsafe.Pointer(m)), C.int(t)))
 }
 
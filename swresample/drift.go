@@ -0,0 +1,151 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package swresample
+
+/*
+#cgo pkg-config: libswresample
+#include <libswresample/swresample.h>
+*/
+import "C"
+import (
+	"math"
+	"time"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+//DriftCompensation makes a Resampler keep its output timestamps continuous when the input ones drift away from the samples,
+//as with a capture device whose clock runs slightly faster or slower than the system one.
+//The fields follow the min_comp, min_hard_comp, max_soft_comp, comp_duration and async options of libswresample.
+//A small drift is corrected softly by stretching or squeezing the samples, a large one by inserting silence or dropping samples.
+type DriftCompensation struct {
+	//Drift below which nothing is corrected, 1ms when zero.
+	MinCompensation time.Duration
+	//Drift above which silence is inserted or samples are dropped, 100ms when zero.
+	HardCompensation time.Duration
+	//Largest factor by which the samples are stretched or squeezed, 0 disabling soft compensation.
+	MaxSoftCompensation float64
+	//Duration over which a soft compensation is spread, 1s when zero.
+	CompensationDuration time.Duration
+	//Simplified setting of aresample: stretch or squeeze by at most Async samples per second of input,
+	//sets MaxSoftCompensation when it is zero and Async is greater than 1.
+	Async int
+}
+
+//DriftMetrics reports the drift measured by a Resampler and the corrections it applied.
+type DriftMetrics struct {
+	//Drift measured on the last input frame with a timestamp, positive when the input is ahead of the output.
+	Drift time.Duration
+	//Largest drift measured, in absolute value.
+	MaxDrift time.Duration
+	//Number of soft compensations applied, and the factor of the last one, positive when stretching.
+	SoftCompensations int
+	Stretch           float64
+	//Number of hard compensations applied, and the total duration of the silence inserted and of the samples dropped.
+	HardCompensations int
+	Inserted          time.Duration
+	Dropped           time.Duration
+}
+
+type drift struct {
+	DriftCompensation
+	metrics DriftMetrics
+	started bool
+	//Timestamp of the next output sample in units of 1/(in_sample_rate * out_sample_rate).
+	outPts int64
+	//Whether no sample was output yet, in which case any drift is corrected at once.
+	first bool
+	//Whether samples are still being dropped, during which the drift is not measured.
+	//libswresample drops them from the next conversions and outputs nothing until it is done.
+	dropping bool
+}
+
+func newDrift(c DriftCompensation, inSampleRate int) *drift {
+	if c.MinCompensation <= 0 {
+		c.MinCompensation = time.Millisecond
+	}
+	if c.HardCompensation <= 0 {
+		c.HardCompensation = 100 * time.Millisecond
+	}
+	if c.CompensationDuration <= 0 {
+		c.CompensationDuration = time.Second
+	}
+	if c.MaxSoftCompensation == 0 && c.Async > 1 {
+		c.MaxSoftCompensation = float64(c.Async) / float64(inSampleRate)
+	}
+	return &drift{DriftCompensation: c}
+}
+
+func (d *drift) advance(units int64) {
+	d.outPts += units
+	if units > 0 {
+		d.first, d.dropping = false, false
+	}
+}
+
+//Measure the drift of the input timestamp pts against the output ones and correct it,
+//returning the timestamp of the next output sample, both in units of 1/(in_sample_rate * out_sample_rate).
+func (r *Resampler) compensate(pts int64) (int64, error) {
+	d := r.drift
+	if pts == math.MinInt64 {
+		return d.outPts, nil
+	}
+	if !d.started {
+		d.started, d.first, d.outPts = true, true, pts
+	}
+	if d.dropping {
+		return d.outPts, nil
+	}
+	inRate, outRate := int64(r.in.SampleRate), int64(r.out.SampleRate)
+	delta := pts - int64(C.swr_get_delay(r.ctx, C.int64_t(inRate*outRate))) - d.outPts
+	drift := time.Duration(float64(delta) / float64(inRate*outRate) * float64(time.Second))
+	d.metrics.Drift = drift
+	if abs(drift) > d.metrics.MaxDrift {
+		d.metrics.MaxDrift = abs(drift)
+	}
+	if abs(drift) <= d.MinCompensation {
+		return d.outPts, nil
+	}
+	var ret C.int
+	switch {
+	case d.first || abs(drift) > d.HardCompensation:
+		d.metrics.HardCompensations++
+		if delta > 0 {
+			n := delta / outRate
+			ret = C.swr_inject_silence(r.ctx, C.int(n))
+			d.metrics.Inserted += time.Duration(n) * time.Second / time.Duration(inRate)
+		} else {
+			n := -delta / inRate
+			ret = C.swr_drop_output(r.ctx, C.int(n))
+			d.metrics.Dropped += time.Duration(n) * time.Second / time.Duration(outRate)
+			d.dropping = true
+		}
+	case d.MaxSoftCompensation > 0:
+		duration := int(float64(outRate) * d.CompensationDuration.Seconds())
+		factor := math.Max(-d.MaxSoftCompensation, math.Min(d.MaxSoftCompensation, drift.Seconds()))
+		comp := int(factor * float64(duration))
+		ret = C.swr_set_compensation(r.ctx, C.int(comp), C.int(duration))
+		d.metrics.SoftCompensations++
+		d.metrics.Stretch = float64(comp) / float64(duration)
+	}
+	if ret < 0 {
+		return 0, &avutil.Error{Num: int(ret)}
+	}
+	return d.outPts, nil
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+//Return the drift measured and the corrections applied, all zero without drift compensation.
+func (r *Resampler) DriftMetrics() DriftMetrics {
+	if r.drift == nil {
+		return DriftMetrics{}
+	}
+	return r.drift.metrics
+}
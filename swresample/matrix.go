@@ -0,0 +1,324 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package swresample
+
+/*
+#cgo pkg-config: libswresample libavutil
+#include <libswresample/swresample.h>
+#include <libswresample/version.h>
+#include <libavutil/channel_layout.h>
+#include <libavutil/mem.h>
+#include <libavutil/opt.h>
+#include <stdlib.h>
+
+#if LIBSWRESAMPLE_VERSION_INT >= AV_VERSION_INT(4, 5, 100)
+#define GOAV_SWR_HAS_CH_LAYOUT 1
+#else
+#define GOAV_SWR_HAS_CH_LAYOUT 0
+#endif
+
+static inline void* goav_ch_layout_alloc(void)
+{
+#if GOAV_SWR_HAS_CH_LAYOUT
+	return av_mallocz(sizeof(AVChannelLayout));
+#else
+	return NULL;
+#endif
+}
+
+static inline void goav_ch_layout_free(void* l)
+{
+#if GOAV_SWR_HAS_CH_LAYOUT
+	if (l)
+		av_channel_layout_uninit(l);
+#endif
+	av_free(l);
+}
+
+static inline int goav_swr_build_matrix(const void* in, uint64_t in_mask, const void* out, uint64_t out_mask,
+	double clev, double slev, double lfe, double maxval, double volume, double* matrix, int stride, int encoding)
+{
+#if GOAV_SWR_HAS_CH_LAYOUT
+	return swr_build_matrix2(in, out, clev, slev, lfe, maxval, volume, matrix, stride, encoding, NULL);
+#else
+	return swr_build_matrix(in_mask, out_mask, clev, slev, lfe, maxval, volume, matrix, stride, encoding, NULL);
+#endif
+}
+
+static inline int goav_swr_set_used_channels(struct SwrContext* s, int nb)
+{
+#if GOAV_SWR_HAS_CH_LAYOUT
+	AVChannelLayout l;
+	av_channel_layout_default(&l, nb);
+	return av_opt_set_chlayout(s, "uchl", &l, 0);
+#else
+	return av_opt_set_int(s, "uch", nb, 0);
+#endif
+}
+*/
+import "C"
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+type MatrixEncoding int
+
+const (
+	AV_MATRIX_ENCODING_NONE           = MatrixEncoding(C.AV_MATRIX_ENCODING_NONE)
+	AV_MATRIX_ENCODING_DOLBY          = MatrixEncoding(C.AV_MATRIX_ENCODING_DOLBY)
+	AV_MATRIX_ENCODING_DPLII          = MatrixEncoding(C.AV_MATRIX_ENCODING_DPLII)
+	AV_MATRIX_ENCODING_DPLIIX         = MatrixEncoding(C.AV_MATRIX_ENCODING_DPLIIX)
+	AV_MATRIX_ENCODING_DPLIIZ         = MatrixEncoding(C.AV_MATRIX_ENCODING_DPLIIZ)
+	AV_MATRIX_ENCODING_DOLBYEX        = MatrixEncoding(C.AV_MATRIX_ENCODING_DOLBYEX)
+	AV_MATRIX_ENCODING_DOLBYHEADPHONE = MatrixEncoding(C.AV_MATRIX_ENCODING_DOLBYHEADPHONE)
+)
+
+//MixOptions drives the mixing matrix built by libswresample, see DefaultMixOptions() for its defaults.
+type MixOptions struct {
+	//Gains of the center, surround and LFE channels when they are mixed into other channels.
+	CenterMixLevel   float64
+	SurroundMixLevel float64
+	LFEMixLevel      float64
+	//Scale the gains down so that no output channel can clip.
+	Normalize bool
+	//Gain applied to the whole matrix.
+	Volume   float64
+	Encoding MatrixEncoding
+}
+
+//Return the options libswresample uses for its own matrix: -3dB for the center and surround channels,
+//the LFE channel dropped, and normalized gains.
+func DefaultMixOptions() MixOptions {
+	return MixOptions{
+		CenterMixLevel:   math.Sqrt2 / 2,
+		SurroundMixLevel: math.Sqrt2 / 2,
+		Normalize:        true,
+		Volume:           1,
+	}
+}
+
+//MixMatrix holds the gain of every input channel in every output channel of a remix.
+//Gains[o][i] is the gain of input channel i in output channel o, the channels being indexes in the layouts.
+type MixMatrix struct {
+	In    avutil.ChannelLayout
+	Out   avutil.ChannelLayout
+	Gains [][]float64
+}
+
+//Create a matrix mixing in into out with all the gains set to 0.
+func NewMixMatrix(in, out avutil.ChannelLayout) *MixMatrix {
+	m := &MixMatrix{In: in, Out: out, Gains: make([][]float64, out.NbChannels)}
+	for o := range m.Gains {
+		m.Gains[o] = make([]float64, in.NbChannels)
+	}
+	return m
+}
+
+//Build the matrix libswresample would use to mix in into out, e.g. to downmix 5.1 to stereo with custom center and LFE levels.
+func BuildMixMatrix(in, out avutil.ChannelLayout, opts MixOptions) (*MixMatrix, error) {
+	inL, outL := C.goav_ch_layout_alloc(), C.goav_ch_layout_alloc()
+	defer C.goav_ch_layout_free(inL)
+	defer C.goav_ch_layout_free(outL)
+	if inL != nil {
+		if err := in.CopyTo(inL); err != nil {
+			return nil, err
+		}
+		if err := out.CopyTo(outL); err != nil {
+			return nil, err
+		}
+	}
+	maxval := C.double(math.MaxInt32)
+	if opts.Normalize {
+		maxval = 1
+	}
+	stride := in.NbChannels
+	matrix := make([]C.double, out.NbChannels*stride)
+	if len(matrix) == 0 {
+		return nil, fmt.Errorf("Cannot mix %s into %s", in, out)
+	}
+	ret := C.goav_swr_build_matrix(inL, C.uint64_t(in.LegacyMask()), outL, C.uint64_t(out.LegacyMask()),
+		C.double(opts.CenterMixLevel), C.double(opts.SurroundMixLevel), C.double(opts.LFEMixLevel),
+		maxval, C.double(opts.Volume), &matrix[0], C.int(stride), C.int(opts.Encoding))
+	if ret < 0 {
+		return nil, fmt.Errorf("Cannot mix %s into %s", in, out)
+	}
+	m := NewMixMatrix(in, out)
+	for o := range m.Gains {
+		for i := range m.Gains[o] {
+			m.Gains[o][i] = float64(matrix[o*stride+i])
+		}
+	}
+	return m, nil
+}
+
+//Create a matrix from the gains of named input channels in named output channels,
+//e.g. {"FL": {"FL": 1, "FC": 0.707, "LFE": 0.5}, "FR": {"FR": 1, "FC": 0.707, "LFE": 0.5}}.
+//Gains that are not given are 0.
+func MixMatrixFromGains(in, out avutil.ChannelLayout, gains map[string]map[string]float64) (*MixMatrix, error) {
+	m := NewMixMatrix(in, out)
+	for oname, inputs := range gains {
+		och, err := avutil.ParseChannel(oname)
+		if err != nil {
+			return nil, err
+		}
+		for iname, gain := range inputs {
+			ich, err := avutil.ParseChannel(iname)
+			if err != nil {
+				return nil, err
+			}
+			if err := m.Set(och, ich, gain); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+//Set the gain of an input channel in an output channel.
+func (m *MixMatrix) Set(out, in avutil.Channel, gain float64) error {
+	o, i := m.Out.Index(out), m.In.Index(in)
+	if o < 0 {
+		return fmt.Errorf("Channel %s is not in the output layout %s", out, m.Out)
+	}
+	if i < 0 {
+		return fmt.Errorf("Channel %s is not in the input layout %s", in, m.In)
+	}
+	m.Gains[o][i] = gain
+	return nil
+}
+
+//Return the gain of an input channel in an output channel, 0 if either is not in its layout.
+func (m *MixMatrix) Gain(out, in avutil.Channel) float64 {
+	o, i := m.Out.Index(out), m.In.Index(in)
+	if o < 0 || i < 0 {
+		return 0
+	}
+	return m.Gains[o][i]
+}
+
+//Describe the matrix with the syntax of the pan filter, e.g. "FL=FL+0.7071*FC|FR=FR+0.7071*FC".
+func (m *MixMatrix) String() string {
+	outputs := make([]string, len(m.Gains))
+	for o, row := range m.Gains {
+		var terms []string
+		for i, gain := range row {
+			if gain == 0 {
+				continue
+			}
+			if gain == 1 {
+				terms = append(terms, m.In.Channel(i).String())
+			} else {
+				terms = append(terms, fmt.Sprintf("%.4g*%s", gain, m.In.Channel(i)))
+			}
+		}
+		if len(terms) == 0 {
+			terms = append(terms, "0*"+m.In.Channel(0).String())
+		}
+		outputs[o] = m.Out.Channel(o).String() + "=" + strings.Replace(strings.Join(terms, "+"), "+-", "-", -1)
+	}
+	return strings.Join(outputs, "|")
+}
+
+func (m *MixMatrix) check(in, out int) error {
+	if m.In.NbChannels != in || m.Out.NbChannels != out || len(m.Gains) != out {
+		return fmt.Errorf("Mixing matrix of %s into %s does not mix %d channels into %d", m.In, m.Out, in, out)
+	}
+	for _, row := range m.Gains {
+		if len(row) != in {
+			return fmt.Errorf("Mixing matrix row has %d gains for %d input channels", len(row), in)
+		}
+	}
+	return nil
+}
+
+//Return the channel map feeding the channels of out from the same channels of in,
+//and silence for those in does not have, e.g. to reorder 5.1 from one channel order to another.
+func RouteChannels(in, out avutil.ChannelLayout) []int {
+	channelMap := make([]int, out.NbChannels)
+	for o := range channelMap {
+		channelMap[o] = in.Index(out.Channel(o))
+	}
+	return channelMap
+}
+
+//Return the channel map of a layout with the channels a and b swapped, e.g. to fix inverted left and right channels.
+func SwapChannels(l avutil.ChannelLayout, a, b avutil.Channel) ([]int, error) {
+	ia, ib := l.Index(a), l.Index(b)
+	if ia < 0 || ib < 0 {
+		return nil, fmt.Errorf("Cannot swap %s and %s in %s", a, b, l)
+	}
+	channelMap := make([]int, l.NbChannels)
+	for i := range channelMap {
+		channelMap[i] = i
+	}
+	channelMap[ia], channelMap[ib] = ib, ia
+	return channelMap, nil
+}
+
+//Return the channel map extracting a single channel of a layout, to be used with a mono output.
+func ExtractChannel(l avutil.ChannelLayout, ch avutil.Channel) ([]int, error) {
+	i := l.Index(ch)
+	if i < 0 {
+		return nil, fmt.Errorf("Channel %s is not in %s", ch, l)
+	}
+	return []int{i}, nil
+}
+
+//Apply the channel map and the mixing matrix of the options, after the context is configured and before it is initialized.
+func (r *Resampler) setMixing(channelMap []int, m *MixMatrix) error {
+	used := r.in.ChannelLayout.NbChannels
+	if channelMap != nil {
+		for _, i := range channelMap {
+			if i < -1 || i >= used {
+				return fmt.Errorf("Channel map index %d out of range for %d channels", i, used)
+			}
+		}
+		if len(channelMap) != used {
+			if ret := C.goav_swr_set_used_channels(r.ctx, C.int(len(channelMap))); ret < 0 {
+				return &avutil.Error{Num: int(ret)}
+			}
+			mapped := avutil.DefaultChannelLayout(len(channelMap))
+			r.mapped = &mapped
+		}
+		//libswresample keeps the pointer rather than a copy.
+		r.channelMap = (*C.int)(C.malloc(C.size_t(len(channelMap)) * C.size_t(unsafe.Sizeof(C.int(0)))))
+		cmap := (*[1 << 16]C.int)(unsafe.Pointer(r.channelMap))[:len(channelMap):len(channelMap)]
+		for i, ch := range channelMap {
+			cmap[i] = C.int(ch)
+		}
+		if ret := C.swr_set_channel_mapping(r.ctx, r.channelMap); ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+	}
+	if m == nil {
+		return nil
+	}
+	mapped := used
+	if channelMap != nil {
+		mapped = len(channelMap)
+	}
+	if err := m.check(mapped, r.out.ChannelLayout.NbChannels); err != nil {
+		return err
+	}
+	//swr_set_matrix() reads as many gains per row as there are input channels before the mapping,
+	//the columns past those of a shorter map are left at 0 and a longer map would lose gains.
+	if mapped > used {
+		return fmt.Errorf("Cannot mix a channel map of %d channels from %d input channels", mapped, used)
+	}
+	matrix := make([]C.double, len(m.Gains)*used)
+	for o, row := range m.Gains {
+		for i, gain := range row {
+			matrix[o*used+i] = C.double(gain)
+		}
+	}
+	if ret := C.swr_set_matrix(r.ctx, &matrix[0], C.int(used)); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
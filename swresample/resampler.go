@@ -0,0 +1,321 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package swresample
+
+/*
+#cgo pkg-config: libswresample libavutil
+#include <libswresample/swresample.h>
+#include <libavutil/frame.h>
+#include <libavutil/opt.h>
+#include <libavutil/samplefmt.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"math"
+	"time"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avutil"
+)
+
+type (
+	DitherMethod int
+	Engine       int
+)
+
+const (
+	SWR_DITHER_NONE                   = DitherMethod(C.SWR_DITHER_NONE)
+	SWR_DITHER_RECTANGULAR            = DitherMethod(C.SWR_DITHER_RECTANGULAR)
+	SWR_DITHER_TRIANGULAR             = DitherMethod(C.SWR_DITHER_TRIANGULAR)
+	SWR_DITHER_TRIANGULAR_HIGHPASS    = DitherMethod(C.SWR_DITHER_TRIANGULAR_HIGHPASS)
+	SWR_DITHER_NS_LIPSHITZ            = DitherMethod(C.SWR_DITHER_NS_LIPSHITZ)
+	SWR_DITHER_NS_F_WEIGHTED          = DitherMethod(C.SWR_DITHER_NS_F_WEIGHTED)
+	SWR_DITHER_NS_MODIFIED_E_WEIGHTED = DitherMethod(C.SWR_DITHER_NS_MODIFIED_E_WEIGHTED)
+	SWR_DITHER_NS_IMPROVED_E_WEIGHTED = DitherMethod(C.SWR_DITHER_NS_IMPROVED_E_WEIGHTED)
+	SWR_DITHER_NS_SHIBATA             = DitherMethod(C.SWR_DITHER_NS_SHIBATA)
+	SWR_DITHER_NS_LOW_SHIBATA         = DitherMethod(C.SWR_DITHER_NS_LOW_SHIBATA)
+	SWR_DITHER_NS_HIGH_SHIBATA        = DitherMethod(C.SWR_DITHER_NS_HIGH_SHIBATA)
+
+	SWR_ENGINE_SWR  = Engine(C.SWR_ENGINE_SWR)
+	SWR_ENGINE_SOXR = Engine(C.SWR_ENGINE_SOXR)
+)
+
+//AudioSpec describes the samples on one side of a Resampler.
+//Planar and packed sample formats can be mixed freely, e.g. fltp from a decoder to s16 for an encoder.
+//TimeBase is the one of the frame timestamps and defaults to 1/SampleRate.
+type AudioSpec struct {
+	SampleFormat  AvSampleFormat
+	SampleRate    int
+	ChannelLayout avutil.ChannelLayout
+	TimeBase      avutil.Rational
+}
+
+func (s AudioSpec) String() string {
+	return fmt.Sprintf("%d Hz %s %s", s.SampleRate, s.ChannelLayout, sampleFmtName(s.SampleFormat))
+}
+
+func (s AudioSpec) timeBase() avutil.Rational {
+	if s.TimeBase.Num() > 0 && s.TimeBase.Den() > 0 {
+		return s.TimeBase
+	}
+	return avutil.NewRational(1, s.SampleRate)
+}
+
+func (s AudioSpec) validate() error {
+	if s.SampleRate <= 0 {
+		return fmt.Errorf("Invalid sample rate %d", s.SampleRate)
+	}
+	if s.ChannelLayout.NbChannels <= 0 {
+		return fmt.Errorf("Invalid channel count %d", s.ChannelLayout.NbChannels)
+	}
+	if C.av_get_sample_fmt_name((C.enum_AVSampleFormat)(s.SampleFormat)) == nil {
+		return fmt.Errorf("Invalid sample format %d", int(s.SampleFormat))
+	}
+	return nil
+}
+
+//Set the format, sample rate and channel layout of an audio frame to those of the spec.
+func (s AudioSpec) setOn(f *avutil.Frame) error {
+	cf := (*C.AVFrame)(unsafe.Pointer(f))
+	cf.format = C.int(s.SampleFormat)
+	cf.sample_rate = C.int(s.SampleRate)
+	return f.SetChLayout(s.ChannelLayout)
+}
+
+func sampleFmtName(f AvSampleFormat) string {
+	if name := C.av_get_sample_fmt_name((C.enum_AVSampleFormat)(f)); name != nil {
+		return C.GoString(name)
+	}
+	return fmt.Sprintf("AvSampleFormat(%d)", int(f))
+}
+
+//ResamplerOptions tunes a Resampler, zero values keep the defaults of libswresample.
+type ResamplerOptions struct {
+	//Dither applied when converting to a format with fewer bits, such as flt to s16, and its scale.
+	Dither      DitherMethod
+	DitherScale float64
+	Engine      Engine
+	//Length of the resampling filter, its number of phases as a power of 2, and its cutoff frequency as a ratio of the Nyquist frequency.
+	FilterSize int
+	PhaseShift int
+	Cutoff     float64
+	//Interpolate linearly between the filter phases.
+	Linear bool
+	//Any other option of libswresample by name, e.g. "precision" for the soxr engine.
+	Options map[string]string
+	//Correct the drift between the input timestamps and the samples, see DriftCompensation.
+	Compensation *DriftCompensation
+	//Input channel feeding every channel given to the mixing, -1 for silence, see RouteChannels(), SwapChannels() and ExtractChannel().
+	//When the map has fewer or more channels than the input, they get the default layout for their number.
+	ChannelMap []int
+	//Mixing matrix replacing the one libswresample builds, its input being the channels of ChannelMap when set,
+	//which must then have no more channels than the input.
+	Matrix *MixMatrix
+}
+
+//Resampler converts audio frames between sample formats, sample rates and channel layouts,
+//and carries their timestamps over.
+type Resampler struct {
+	ctx        *C.struct_SwrContext
+	in         AudioSpec
+	out        AudioSpec
+	drift      *drift
+	channelMap *C.int
+	mapped     *avutil.ChannelLayout
+}
+
+//Create a Resampler converting samples described by in into samples described by out, opts may be nil.
+func NewResampler(in, out AudioSpec, opts *ResamplerOptions) (*Resampler, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	if err := out.validate(); err != nil {
+		return nil, err
+	}
+	r := &Resampler{ctx: C.swr_alloc(), in: in, out: out}
+	if r.ctx == nil {
+		return nil, &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+	}
+	if err := r.configure(opts); err != nil {
+		r.Free()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Resampler) configure(opts *ResamplerOptions) error {
+	ifr, ofr := avutil.AvFrameAlloc(), avutil.AvFrameAlloc()
+	defer avutil.AvFrameFree(ifr)
+	defer avutil.AvFrameFree(ofr)
+	if err := r.in.setOn(ifr); err != nil {
+		return err
+	}
+	if err := r.out.setOn(ofr); err != nil {
+		return err
+	}
+	ret := C.swr_config_frame(r.ctx, (*C.AVFrame)(unsafe.Pointer(ofr)), (*C.AVFrame)(unsafe.Pointer(ifr)))
+	if ret < 0 {
+		return fmt.Errorf("Cannot convert %s to %s", r.in, r.out)
+	}
+	if opts != nil {
+		if err := r.setOptions(opts); err != nil {
+			return err
+		}
+		if err := r.setMixing(opts.ChannelMap, opts.Matrix); err != nil {
+			return err
+		}
+		if opts.Compensation != nil {
+			r.drift = newDrift(*opts.Compensation, r.in.SampleRate)
+			//Soft compensation needs the resampler even when the sample rates match, and enabling it later resets the context.
+			if r.drift.MaxSoftCompensation > 0 {
+				if err := r.setOption("flags", "res"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if ret := C.swr_init(r.ctx); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
+
+func (r *Resampler) setOptions(opts *ResamplerOptions) error {
+	var names, values []string
+	set := func(name string, value interface{}, isSet bool) {
+		if isSet {
+			names, values = append(names, name), append(values, fmt.Sprint(value))
+		}
+	}
+	set("dither_method", int(opts.Dither), opts.Dither != SWR_DITHER_NONE)
+	set("dither_scale", opts.DitherScale, opts.DitherScale != 0)
+	set("resampler", int(opts.Engine), opts.Engine != SWR_ENGINE_SWR)
+	set("filter_size", opts.FilterSize, opts.FilterSize != 0)
+	set("phase_shift", opts.PhaseShift, opts.PhaseShift != 0)
+	set("cutoff", opts.Cutoff, opts.Cutoff != 0)
+	set("linear_interp", 1, opts.Linear)
+	for name, value := range opts.Options {
+		set(name, value, true)
+	}
+	for i, name := range names {
+		if err := r.setOption(name, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resampler) setOption(name, value string) error {
+	cname, cvalue := C.CString(name), C.CString(value)
+	defer C.free(unsafe.Pointer(cname))
+	defer C.free(unsafe.Pointer(cvalue))
+	ret := C.av_opt_set(unsafe.Pointer(r.ctx), cname, cvalue, 0)
+	if ret == C.AVERROR_OPTION_NOT_FOUND {
+		return fmt.Errorf("Unknown resampler option %q", name)
+	}
+	if ret < 0 {
+		return fmt.Errorf("Invalid value %q for resampler option %s", value, name)
+	}
+	return nil
+}
+
+//Convert the samples of src into dst and set the timestamp of dst, src being nil to drain the context as Flush() does.
+//If dst has no buffers they are allocated with the output spec and room for all the samples available,
+//otherwise dst must match the output spec and receives at most its nb_samples samples, the others staying buffered.
+//dst may get no samples at all, e.g. while the resampling filter fills up.
+//The timestamp of src is rescaled from the input time base, and extrapolated from the previous frames when unset.
+//With drift compensation the output timestamps are continuous and the drift of the input ones is corrected instead.
+func (r *Resampler) Convert(dst, src *avutil.Frame) error {
+	cdst := (*C.AVFrame)(unsafe.Pointer(dst))
+	if cdst.linesize[0] == 0 {
+		if err := r.out.setOn(dst); err != nil {
+			return err
+		}
+	}
+	inTb, outTb := r.in.timeBase(), r.out.timeBase()
+	rates := int64(r.in.SampleRate) * int64(r.out.SampleRate)
+	//swr_next_pts() works in units of 1/(in_sample_rate * out_sample_rate), INT64_MIN asks for the extrapolated timestamp.
+	pts := int64(math.MinInt64)
+	var csrc *C.AVFrame
+	if src != nil {
+		csrc = (*C.AVFrame)(unsafe.Pointer(src))
+		if src.Pts() != avutil.AV_NOPTS_VALUE {
+			pts = avutil.AvRescaleRnd(src.Pts(), int64(inTb.Num())*rates, int64(inTb.Den()), avutil.AV_ROUND_NEAR_INF)
+		}
+	}
+	var next int64
+	if r.drift != nil {
+		var err error
+		if next, err = r.compensate(pts); err != nil {
+			return err
+		}
+	} else {
+		next = int64(C.swr_next_pts(r.ctx, C.int64_t(pts)))
+	}
+	if csrc != nil && r.mapped != nil {
+		//When a channel map changes the channel count the context expects the layout of the mapped channels on the input frames.
+		mapped := avutil.AvFrameAlloc()
+		defer avutil.AvFrameFree(mapped)
+		if ret := avutil.AvFrameRef(mapped, src); ret < 0 {
+			return &avutil.Error{Num: ret}
+		}
+		if err := mapped.SetChLayout(*r.mapped); err != nil {
+			return err
+		}
+		csrc = (*C.AVFrame)(unsafe.Pointer(mapped))
+	}
+	if ret := C.swr_convert_frame(r.ctx, cdst, csrc); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	if r.drift != nil {
+		r.drift.advance(int64(cdst.nb_samples) * int64(r.in.SampleRate))
+	}
+	dst.SetPts(avutil.AvRescaleRnd(next, int64(outTb.Den()), int64(outTb.Num())*rates, avutil.AV_ROUND_NEAR_INF))
+	return nil
+}
+
+//Drain the samples buffered in the context into dst, which gets no samples once the context is empty.
+//The context can be used again for a new stream afterwards.
+func (r *Resampler) Flush(dst *avutil.Frame) error {
+	return r.Convert(dst, nil)
+}
+
+//Return the delay the next input sample will experience relative to the next output sample,
+//which is the duration of the samples buffered in the context.
+func (r *Resampler) Delay() time.Duration {
+	return time.Duration(C.swr_get_delay(r.ctx, C.int64_t(time.Second)))
+}
+
+//Return the delay as a number of output samples, rounded up.
+func (r *Resampler) DelaySamples() int64 {
+	return int64(C.swr_get_delay(r.ctx, C.int64_t(r.out.SampleRate)))
+}
+
+//Return an upper bound on the number of output samples the next conversion of n input samples produces.
+func (r *Resampler) OutputSamples(n int) int {
+	return int(C.swr_get_out_samples(r.ctx, C.int(n)))
+}
+
+//Return the spec of the input samples.
+func (r *Resampler) Input() AudioSpec {
+	return r.in
+}
+
+//Return the spec of the output samples.
+func (r *Resampler) Output() AudioSpec {
+	return r.out
+}
+
+//Return the underlying libswresample context.
+func (r *Resampler) Context() *Context {
+	return (*Context)(r.ctx)
+}
+
+func (r *Resampler) Free() {
+	C.swr_free(&r.ctx)
+	C.free(unsafe.Pointer(r.channelMap))
+	r.channelMap = nil
+}
@@ -0,0 +1,561 @@
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+// Giorgis (habtom@giorgis.io)
+
+package swscale
+
+/*
+#cgo pkg-config: libswscale libavutil
+#include <libswscale/swscale.h>
+#include <libswscale/version.h>
+#include <libavutil/common.h>
+#include <libavutil/frame.h>
+#include <libavutil/imgutils.h>
+#include <libavutil/log.h>
+#include <libavutil/opt.h>
+#include <libavutil/pixdesc.h>
+#include <stdlib.h>
+
+#if LIBSWSCALE_VERSION_INT >= AV_VERSION_INT(6, 1, 100)
+#define GOAV_SWS_HAS_THREADS 1
+#else
+#define GOAV_SWS_HAS_THREADS 0
+#endif
+
+static inline int goav_sws_scale_frame(struct SwsContext* c, AVFrame* dst, const AVFrame* src)
+{
+#if GOAV_SWS_HAS_THREADS
+	int ret = sws_scale_frame(c, dst, src);
+#else
+	int ret = sws_scale(c, (const uint8_t* const*)src->data, src->linesize, 0, src->height, dst->data, dst->linesize);
+#endif
+	if (ret < 0)
+		return ret;
+	return av_frame_copy_props(dst, src);
+}
+
+static inline struct SwsContext* goav_sws_context(int srcw, int srch, int src_format, int src_range,
+	int dstw, int dsth, int dst_format, int dst_range, int flags, int threads)
+{
+	struct SwsContext* c = sws_alloc_context();
+	if (!c)
+		return NULL;
+	av_opt_set_int(c, "srcw", srcw, 0);
+	av_opt_set_int(c, "srch", srch, 0);
+	av_opt_set_int(c, "src_format", src_format, 0);
+	av_opt_set_int(c, "src_range", src_range, 0);
+	av_opt_set_int(c, "dstw", dstw, 0);
+	av_opt_set_int(c, "dsth", dsth, 0);
+	av_opt_set_int(c, "dst_format", dst_format, 0);
+	av_opt_set_int(c, "dst_range", dst_range, 0);
+	av_opt_set_int(c, "sws_flags", flags, 0);
+#if GOAV_SWS_HAS_THREADS
+	av_opt_set_int(c, "threads", threads, 0);
+#endif
+	if (sws_init_context(c, NULL, NULL) < 0) {
+		sws_freeContext(c);
+		return NULL;
+	}
+	return c;
+}
+
+//Scale the source rows [srcy, srcy + srch) into tmp with a context made for their height,
+//then copy the rows [y, y + h) of the destination, tmp starting at the destination row outy.
+static inline int goav_sws_scale_band(struct SwsContext* c, AVFrame* dst, AVFrame* tmp, const AVFrame* src,
+	int srcy, int srch, int outy, int y, int h)
+{
+	const AVPixFmtDescriptor* sd = av_pix_fmt_desc_get(src->format);
+	const AVPixFmtDescriptor* dd = av_pix_fmt_desc_get(dst->format);
+	const uint8_t* in[4] = {NULL};
+	int linesizes[4];
+	int i, ret;
+	for (i = 0; i < 4 && src->data[i]; i++) {
+		int shift = i == 1 || i == 2 ? sd->log2_chroma_h : 0;
+		in[i] = src->data[i] + (srcy >> shift) * src->linesize[i];
+	}
+	ret = sws_scale(c, in, src->linesize, 0, srch, tmp->data, tmp->linesize);
+	if (ret < 0)
+		return ret;
+	if ((ret = av_image_fill_linesizes(linesizes, dst->format, dst->width)) < 0)
+		return ret;
+	for (i = 0; i < 4 && dst->data[i]; i++) {
+		int shift = i == 1 || i == 2 ? dd->log2_chroma_h : 0;
+		av_image_copy_plane(dst->data[i] + (y >> shift) * dst->linesize[i], dst->linesize[i],
+			tmp->data[i] + ((y - outy) >> shift) * tmp->linesize[i], tmp->linesize[i],
+			linesizes[i], AV_CEIL_RSHIFT(h, shift));
+	}
+	return 0;
+}
+
+//Return the vertical chroma subsampling of a format which can be scaled in bands, -1 for the others:
+//paletted, bitstream, Bayer and hardware formats, or destinations of less than 8 bits, which are dithered across rows.
+static inline int goav_pix_fmt_band_shift(enum AVPixelFormat p, int dst)
+{
+	const AVPixFmtDescriptor* desc = av_pix_fmt_desc_get(p);
+	int i;
+	if (!desc || desc->flags & (AV_PIX_FMT_FLAG_PAL | AV_PIX_FMT_FLAG_BITSTREAM | AV_PIX_FMT_FLAG_BAYER | AV_PIX_FMT_FLAG_HWACCEL))
+		return -1;
+	for (i = 0; dst && i < desc->nb_components; i++)
+		if (desc->comp[i].depth < 8)
+			return -1;
+	return desc->log2_chroma_h;
+}
+
+static inline int goav_pix_fmt_depth(enum AVPixelFormat p)
+{
+	const AVPixFmtDescriptor* desc = av_pix_fmt_desc_get(p);
+	return desc ? desc->comp[0].depth : 0;
+}
+
+static inline int goav_pix_fmt_is_rgb(enum AVPixelFormat p)
+{
+	const AVPixFmtDescriptor* desc = av_pix_fmt_desc_get(p);
+	return desc && (desc->flags & AV_PIX_FMT_FLAG_RGB);
+}
+
+static inline void goav_sws_warn(struct SwsContext* c, const char* msg)
+{
+	av_log(c, AV_LOG_WARNING, "%s\n", msg);
+}
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avutil"
+)
+
+//FrameSpec describes the pictures on one side of a Scaler.
+//The color properties use the types of avcodec.Context, unspecified ones are taken from the frames,
+//and on the destination side default to those of the source, except for the range which defaults to limited for YUV.
+//libswscale converts the YUV matrix and the range, the transfer characteristic and the primaries are only carried over.
+type FrameSpec struct {
+	Width          int
+	Height         int
+	PixelFormat    PixelFormat
+	Colorspace     avcodec.AvColorSpace
+	ColorRange     avcodec.AvColorRange
+	ColorTrc       avcodec.AvColorTransferCharacteristic
+	ColorPrimaries avcodec.AvColorPrimaries
+}
+
+func (s FrameSpec) String() string {
+	return fmt.Sprintf("%dx%d %s", s.Width, s.Height, pixFmtName(s.PixelFormat))
+}
+
+func (s FrameSpec) sameLayout(o FrameSpec) bool {
+	return s.Width == o.Width && s.Height == o.Height && s.PixelFormat == o.PixelFormat
+}
+
+func (s FrameSpec) isRGB() bool {
+	return C.goav_pix_fmt_is_rgb((C.enum_AVPixelFormat)(s.PixelFormat)) != 0
+}
+
+//Fill the unspecified color properties of s from o.
+func (s FrameSpec) withColorsOf(o FrameSpec) FrameSpec {
+	if s.Colorspace == avcodec.AVCOL_SPC_UNSPECIFIED || s.Colorspace == avcodec.AVCOL_SPC_RGB && !s.isRGB() {
+		s.Colorspace = o.Colorspace
+	}
+	if s.ColorRange == avcodec.AVCOL_RANGE_UNSPECIFIED {
+		s.ColorRange = o.ColorRange
+	}
+	if s.ColorTrc == 0 || s.ColorTrc == avcodec.AVCOL_TRC_UNSPECIFIED {
+		s.ColorTrc = o.ColorTrc
+	}
+	if s.ColorPrimaries == 0 || s.ColorPrimaries == avcodec.AVCOL_PRI_UNSPECIFIED {
+		s.ColorPrimaries = o.ColorPrimaries
+	}
+	return s
+}
+
+//Return the range argument of sws_setColorspaceDetails(), 1 for full range.
+func (s FrameSpec) fullRange() C.int {
+	if s.ColorRange == avcodec.AVCOL_RANGE_JPEG {
+		return 1
+	}
+	return 0
+}
+
+func frameSpec(f *C.AVFrame) FrameSpec {
+	return FrameSpec{
+		Width:          int(f.width),
+		Height:         int(f.height),
+		PixelFormat:    PixelFormat(f.format),
+		Colorspace:     avcodec.AvColorSpace(f.colorspace),
+		ColorRange:     avcodec.AvColorRange(f.color_range),
+		ColorTrc:       avcodec.AvColorTransferCharacteristic(f.color_trc),
+		ColorPrimaries: avcodec.AvColorPrimaries(f.color_primaries),
+	}
+}
+
+func pixFmtName(p PixelFormat) string {
+	if name := C.av_get_pix_fmt_name((C.enum_AVPixelFormat)(p)); name != nil {
+		return C.GoString(name)
+	}
+	return fmt.Sprintf("PixelFormat(%d)", int(p))
+}
+
+//Scaler converts frames between sizes, pixel formats, YUV colorspaces and ranges.
+//The source spec follows the frames given to Scale(), the context being rebuilt when they change mid-stream,
+//while the destination layout is fixed.
+type Scaler struct {
+	ctx     *C.struct_SwsContext
+	bands   []band
+	src     FrameSpec
+	dst     FrameSpec
+	out     FrameSpec
+	flags   int
+	threads int
+	hdrLost bool
+}
+
+//Create a Scaler converting pictures described by src into pictures described by dst,
+//flags being a combination of SWS_* such as SWS_BICUBIC.
+func NewScaler(src, dst FrameSpec, flags int) (*Scaler, error) {
+	if SwsIssupportedoutput(dst.PixelFormat) <= 0 {
+		return nil, fmt.Errorf("Unsupported output pixel format %s", pixFmtName(dst.PixelFormat))
+	}
+	if dst.Width <= 0 || dst.Height <= 0 {
+		return nil, fmt.Errorf("Invalid output size %dx%d", dst.Width, dst.Height)
+	}
+	s := &Scaler{dst: dst, flags: flags, threads: 1}
+	if err := s.configure(src, dst); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//Resolve the unspecified color properties of the destination.
+func resolveDestination(src, dst FrameSpec) FrameSpec {
+	out := dst.withColorsOf(src)
+	if dst.ColorRange == avcodec.AVCOL_RANGE_UNSPECIFIED {
+		out.ColorRange = avcodec.AVCOL_RANGE_MPEG
+		if out.isRGB() {
+			out.ColorRange = avcodec.AVCOL_RANGE_JPEG
+		}
+	}
+	return out
+}
+
+//Get a context for the source spec and set its colorspace details, the current context is kept if nothing changed.
+//The ranges select the conversion routines when the context is initialized,
+//so the context is created with them rather than through sws_getCachedContext().
+func (s *Scaler) configure(src, dst FrameSpec) error {
+	if SwsIssupportedinput(src.PixelFormat) <= 0 {
+		return fmt.Errorf("Unsupported input pixel format %s", pixFmtName(src.PixelFormat))
+	}
+	out := resolveDestination(src, dst)
+	if s.ctx != nil && src == s.src && out == s.out {
+		return nil
+	}
+	s.Free()
+	threads := s.threads
+	if !sliceThreads {
+		threads = 1
+	}
+	ctx, err := newContext(src, out, s.flags, threads)
+	if err != nil {
+		return err
+	}
+	s.ctx = ctx
+	if !sliceThreads {
+		if err := s.splitBands(src, out); err != nil {
+			s.Free()
+			return err
+		}
+	}
+	s.src, s.out = src, out
+	s.hdrLost = src.ColorTrc.IsHDR() && (out.ColorTrc != src.ColorTrc || C.goav_pix_fmt_depth((C.enum_AVPixelFormat)(out.PixelFormat)) < 10)
+	if s.hdrLost {
+		msg := C.CString(fmt.Sprintf("Converting %s %s to %s %s loses the HDR transfer characteristic, no tone mapping is applied",
+			pixFmtName(src.PixelFormat), src.ColorTrc, pixFmtName(out.PixelFormat), out.ColorTrc))
+		C.goav_sws_warn(ctx, msg)
+		C.free(unsafe.Pointer(msg))
+	}
+	return nil
+}
+
+//Create a context converting src into out and set its colorspace details.
+func newContext(src, out FrameSpec, flags, threads int) (*C.struct_SwsContext, error) {
+	ctx := C.goav_sws_context(
+		C.int(src.Width), C.int(src.Height), C.int(src.PixelFormat), src.fullRange(),
+		C.int(out.Width), C.int(out.Height), C.int(out.PixelFormat), out.fullRange(),
+		C.int(flags), C.int(threads))
+	if ctx == nil {
+		return nil, fmt.Errorf("Cannot scale %s to %s", src, out)
+	}
+	ret := C.sws_setColorspaceDetails(ctx,
+		C.sws_getCoefficients(C.int(src.Colorspace)), src.fullRange(),
+		C.sws_getCoefficients(C.int(out.Colorspace)), out.fullRange(),
+		0, 1<<16, 1<<16)
+	if ret < 0 {
+		C.sws_freeContext(ctx)
+		return nil, fmt.Errorf("Cannot convert colorspace %s/%s to %s/%s", src.Colorspace, src.ColorRange, out.Colorspace, out.ColorRange)
+	}
+	return ctx, nil
+}
+
+//Whether libswscale splits frames into slices processed by its own threads,
+//otherwise Scaler runs one context per band of rows on goroutines.
+var sliceThreads = C.GOAV_SWS_HAS_THREADS != 0
+
+//The size of the largest filter before libswscale scales through an intermediate picture.
+const maxFilterSize = 256
+
+//Number of source pixels spanned by the filters when upscaling, see initFilter() in libswscale.
+var filterSizeFactors = []struct{ flag, size int }{
+	{SWS_POINT, 1}, {SWS_AREA, 2}, {SWS_FAST_BILINEAR, 2}, {SWS_BILINEAR, 2}, {SWS_BICUBIC, 4}, {SWS_BICUBLIN, 4},
+	{SWS_LANCZOS, 6}, {SWS_X, 8}, {SWS_GAUSS, 8}, {SWS_SINC, 20}, {SWS_SPLINE, 20},
+}
+
+//Return an upper bound of the number of taps of the filter scaling srcSize pixels to dstSize.
+func filterSize(flags, srcSize, dstSize int) int {
+	factor := 20
+	for _, f := range filterSizeFactors {
+		if flags&f.flag != 0 {
+			factor = f.size
+		}
+	}
+	if srcSize <= dstSize {
+		return 1 + factor
+	}
+	return 1 + (factor*srcSize+dstSize-1)/dstSize
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+//A band of destination rows scaled by its own context.
+//The context scales the source rows [srcY, srcY + srcH) into tmp, whose first row is the destination row outY,
+//and the rows [y, y + h) are copied to the destination, the others only feeding the filter taps.
+type band struct {
+	ctx        *C.struct_SwsContext
+	tmp        *C.AVFrame
+	srcY, srcH int
+	outY, y, h int
+}
+
+//Split the scaling of src into out into bands of destination rows, one per thread.
+//Every band context sees an image of the same scale factor whose rows start at the same filter phase and dither pattern
+//as those of the whole frame, with enough rows around the band for the filter taps, so the band is scaled exactly as by a single context.
+//The frames are scaled by the single context when the geometry does not allow that.
+func (s *Scaler) splitBands(src, out FrameSpec) error {
+	n := s.threads
+	if n == 0 {
+		n = runtime.NumCPU()
+	}
+	rows := bandRows(src, out, s.flags, n)
+	if rows == 0 {
+		return nil
+	}
+	//The source rows per destination row, as a fraction.
+	g := gcd(src.Height, out.Height)
+	p, q := src.Height/g, out.Height/g
+	margin := bandMargin(src, out, s.flags)
+	for y := 0; y < out.Height; y += rows {
+		b := band{y: y, h: rows, outY: y - margin}
+		if b.y+b.h > out.Height {
+			b.h = out.Height - b.y
+		}
+		if b.outY < 0 {
+			b.outY = 0
+		}
+		outEnd := b.y + b.h + margin
+		if outEnd > out.Height {
+			outEnd = out.Height
+		}
+		b.srcY, b.srcH = b.outY*p/q, (outEnd-b.outY)*p/q
+		bsrc, bout := src, out
+		bsrc.Height, bout.Height = b.srcH, outEnd-b.outY
+		ctx, err := newContext(bsrc, bout, s.flags, 1)
+		if err != nil {
+			return err
+		}
+		b.ctx = ctx
+		b.tmp = C.av_frame_alloc()
+		if b.tmp == nil {
+			C.sws_freeContext(ctx)
+			return &avutil.Error{Num: avutil.AVERROR_ENOMEM}
+		}
+		s.bands = append(s.bands, b)
+		b.tmp.width, b.tmp.height, b.tmp.format = C.int(bout.Width), C.int(bout.Height), C.int(bout.PixelFormat)
+		if ret := C.av_frame_get_buffer(b.tmp, 0); ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+	}
+	return nil
+}
+
+//Return the number of destination rows of each of the n bands scaling src into out, 0 to scale the frames as a whole.
+func bandRows(src, out FrameSpec, flags, n int) int {
+	srcShift := int(C.goav_pix_fmt_band_shift((C.enum_AVPixelFormat)(src.PixelFormat), 0))
+	dstShift := int(C.goav_pix_fmt_band_shift((C.enum_AVPixelFormat)(out.PixelFormat), 1))
+	if n < 2 || srcShift < 0 || dstShift < 0 || flags&SWS_ERROR_DIFFUSION != 0 ||
+		filterSize(flags, src.Width, out.Width) > maxFilterSize || filterSize(flags, src.Height, out.Height) > maxFilterSize {
+		return 0
+	}
+	//The chroma heights must have the scale factor of the luma ones, whose fixed point step must be exact.
+	if src.Height%(1<<srcShift) != 0 || out.Height%(1<<dstShift) != 0 || (int64(src.Height)<<16)%int64(out.Height) != 0 {
+		return 0
+	}
+	step := bandStep(src, out)
+	rows := (out.Height + n - 1) / n
+	rows = (rows + step - 1) / step * step
+	if rows >= out.Height {
+		return 0
+	}
+	return rows
+}
+
+//Return the number of destination rows the bands start on a multiple of,
+//rows whose source position is a whole source row of the chroma subsampling and whose dither rows are the first ones, 8 for luma and chroma.
+func bandStep(src, out FrameSpec) int {
+	srcShift := int(C.goav_pix_fmt_band_shift((C.enum_AVPixelFormat)(src.PixelFormat), 0))
+	dstShift := int(C.goav_pix_fmt_band_shift((C.enum_AVPixelFormat)(out.PixelFormat), 1))
+	g := gcd(src.Height, out.Height)
+	p, q := src.Height/g, out.Height/g
+	dither := 8 << dstShift
+	step := q / gcd(q, dither) * dither
+	for step*p/q%(1<<srcShift) != 0 {
+		step *= 2
+	}
+	return step
+}
+
+//Return the number of destination rows scaled around a band, a multiple of its step
+//feeding enough source rows to the vertical filter taps of the luma and the chroma rows.
+func bandMargin(src, out FrameSpec, flags int) int {
+	srcShift := int(C.goav_pix_fmt_band_shift((C.enum_AVPixelFormat)(src.PixelFormat), 0))
+	step := bandStep(src, out)
+	srcStep := step * src.Height / out.Height
+	taps := (filterSize(flags, src.Height, out.Height) + 2) << srcShift
+	return (taps + srcStep - 1) / srcStep * step
+}
+
+//Scale src into dst with the band contexts, on one goroutine per band.
+func (s *Scaler) scaleBands(dst, src *C.AVFrame) error {
+	rets := make([]C.int, len(s.bands))
+	var wg sync.WaitGroup
+	for i := range s.bands {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b := &s.bands[i]
+			rets[i] = C.goav_sws_scale_band(b.ctx, dst, b.tmp, src, C.int(b.srcY), C.int(b.srcH), C.int(b.outY), C.int(b.y), C.int(b.h))
+		}(i)
+	}
+	wg.Wait()
+	for _, ret := range rets {
+		if ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+	}
+	if ret := C.av_frame_copy_props(dst, src); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	return nil
+}
+
+//Scale src into dst, handling all the planes of both frames, and copy the frame properties such as the timestamps.
+//If dst has no buffers they are allocated with the destination spec, otherwise they must match its layout,
+//are made writable, copying them when they are shared, and the color properties set on dst take precedence over those of the spec.
+//The color properties of dst are set to those of the converted picture.
+func (s *Scaler) Scale(dst, src *avutil.Frame) error {
+	csrc := (*C.AVFrame)(unsafe.Pointer(src))
+	cdst := (*C.AVFrame)(unsafe.Pointer(dst))
+	want := s.dst
+	if cdst.buf[0] == nil {
+		cdst.width, cdst.height, cdst.format = C.int(s.dst.Width), C.int(s.dst.Height), C.int(s.dst.PixelFormat)
+		if ret := C.av_frame_get_buffer(cdst, 0); ret < 0 {
+			return &avutil.Error{Num: int(ret)}
+		}
+	} else if got := frameSpec(cdst); !got.sameLayout(s.dst) {
+		return fmt.Errorf("Destination frame is %s, expected %s", got, s.dst)
+	} else {
+		want = got.withColorsOf(s.dst)
+		//The buffers may be shared with other frames, e.g. an output of the previous call still queued to an encoder.
+		if ret := avutil.AvFrameMakeWritable(dst); ret < 0 {
+			return &avutil.Error{Num: ret}
+		}
+	}
+	if err := s.configure(frameSpec(csrc).withColorsOf(s.src), want); err != nil {
+		return err
+	}
+	if len(s.bands) > 0 {
+		if err := s.scaleBands(cdst, csrc); err != nil {
+			return err
+		}
+	} else if ret := C.goav_sws_scale_frame(s.ctx, cdst, csrc); ret < 0 {
+		return &avutil.Error{Num: int(ret)}
+	}
+	cdst.colorspace = C.enum_AVColorSpace(s.out.Colorspace)
+	cdst.color_range = C.enum_AVColorRange(s.out.ColorRange)
+	cdst.color_trc = C.enum_AVColorTransferCharacteristic(s.out.ColorTrc)
+	cdst.color_primaries = C.enum_AVColorPrimaries(s.out.ColorPrimaries)
+	return nil
+}
+
+//Split the scaling of every frame into slices processed by n threads, 0 using one thread per CPU.
+//Every slice is scaled by the same code as the single-threaded path, which n = 1 restores, so the output does not change.
+//libswscale 6.1.100 and later scale the slices on their own threads, older versions scale bands of rows with one context each on goroutines,
+//which needs the bands to start on rows of the same filter phase and dither pattern as the first one:
+//the vertical scale factor must be exact in 16 bit fixed point, e.g. 2, 3/2 or 1/4, with heights that are multiples of the chroma subsampling.
+//AVERROR_ENOSYS is returned when the current source cannot be split that way, later sources which cannot are scaled on one thread.
+func (s *Scaler) SetThreads(n int) error {
+	if n < 0 {
+		return fmt.Errorf("Invalid thread count %d", n)
+	}
+	if !sliceThreads && n != 1 && bandRows(s.src, resolveDestination(s.src, s.dst), s.flags, 2) == 0 {
+		return &avutil.Error{Num: avutil.AVERROR_ENOSYS}
+	}
+	if n == s.threads {
+		return nil
+	}
+	s.threads = n
+	s.Free()
+	return s.configure(s.src, s.dst)
+}
+
+//Return the number of threads set with SetThreads().
+func (s *Scaler) Threads() int {
+	return s.threads
+}
+
+//Return the spec of the source frames the context is currently configured for.
+func (s *Scaler) Source() FrameSpec {
+	return s.src
+}
+
+//Return the spec of the frames produced, with the color properties resolved against the current source.
+func (s *Scaler) Destination() FrameSpec {
+	return s.out
+}
+
+//Report whether the current conversion loses the HDR transfer characteristic of the source,
+//either because the destination declares another one or because its bit depth is lower than 10.
+//libswscale does no tone mapping, use a filter such as zscale or tonemap for that.
+func (s *Scaler) LosesHDR() bool {
+	return s.hdrLost
+}
+
+//Return the underlying libswscale context, which changes when the source spec does.
+func (s *Scaler) Context() *Context {
+	return (*Context)(s.ctx)
+}
+
+func (s *Scaler) Free() {
+	C.sws_freeContext(s.ctx)
+	s.ctx = nil
+	for _, b := range s.bands {
+		C.sws_freeContext(b.ctx)
+		C.av_frame_free(&b.tmp)
+	}
+	s.bands = nil
+}
@@ -0,0 +1,120 @@
+package swscale
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/alon-ne/goav/avcodec"
+	"github.com/alon-ne/goav/avfilter"
+	"github.com/alon-ne/goav/avutil"
+)
+
+var (
+	scaleInput  = FrameSpec{Width: 3840, Height: 2160, PixelFormat: avcodec.AV_PIX_FMT_YUV420P}
+	scaleOutput = FrameSpec{Width: 640, Height: 360, PixelFormat: avcodec.AV_PIX_FMT_YUV420P}
+)
+
+//Return a 4K frame of a test pattern, to be freed by the caller.
+func testFrame(tb testing.TB) *avutil.Frame {
+	tb.Helper()
+	pixFmt := avcodec.PixelFormat(scaleInput.PixelFormat)
+	src, err := avfilter.TestSrc2(avfilter.VideoSourceSpec{Width: scaleInput.Width, Height: scaleInput.Height,
+		PixelFormat: &pixFmt})
+	if err != nil {
+		tb.Fatalf("TestSrc2() failed: %#v", err)
+	}
+	defer src.Free()
+	frame, err := src.Next()
+	if err != nil {
+		tb.Fatalf("Next() failed: %#v", err)
+	}
+	return frame
+}
+
+//Return a scaler downscaling the test frame to a thumbnail with the given number of threads.
+func testScaler(tb testing.TB, threads int) *Scaler {
+	tb.Helper()
+	s, err := NewScaler(scaleInput, scaleOutput, SWS_BICUBIC)
+	if err != nil {
+		tb.Fatalf("NewScaler() failed: %#v", err)
+	}
+	if err := s.SetThreads(threads); err != nil {
+		s.Free()
+		if e, ok := err.(*avutil.Error); ok && e.Num == avutil.AVERROR_ENOSYS {
+			tb.Skipf("SetThreads(%d) is not supported: %#v", threads, err)
+		}
+		tb.Fatalf("SetThreads(%d) failed: %#v", threads, err)
+	}
+	return s
+}
+
+func scaledImage(t *testing.T, s *Scaler, frame *avutil.Frame) []byte {
+	t.Helper()
+	dst := avutil.AvFrameAlloc()
+	defer avutil.AvFrameFree(dst)
+	if err := s.Scale(dst, frame); err != nil {
+		t.Fatalf("Scale() with %d threads failed: %#v", s.Threads(), err)
+	}
+	buf, err := avcodec.PackImage(dst, 1)
+	if err != nil {
+		t.Fatalf("PackImage() failed: %#v", err)
+	}
+	return buf
+}
+
+func TestScaleThreadsIdenticalOutput(t *testing.T) {
+	testIdenticalOutput(t)
+}
+
+//Scale on goroutines even when libswscale has threads.
+func TestScaleBandsIdenticalOutput(t *testing.T) {
+	if sliceThreads {
+		sliceThreads = false
+		defer func() {
+			sliceThreads = true
+		}()
+	}
+	testIdenticalOutput(t)
+}
+
+func testIdenticalOutput(t *testing.T) {
+	frame := testFrame(t)
+	defer avutil.AvFrameFree(frame)
+	single := testScaler(t, 1)
+	defer single.Free()
+	want := scaledImage(t, single, frame)
+	for _, threads := range []int{2, 3, runtime.NumCPU()} {
+		s := testScaler(t, threads)
+		if !sliceThreads && threads > 1 && len(s.bands) < 2 {
+			t.Errorf("%d bands for %d threads", len(s.bands), s.Threads())
+		}
+		if got := scaledImage(t, s, frame); !bytes.Equal(got, want) {
+			t.Errorf("Output of %d threads differs from the single-threaded one", s.Threads())
+		}
+		s.Free()
+	}
+}
+
+func benchmarkScale(b *testing.B, threads int) {
+	frame := testFrame(b)
+	defer avutil.AvFrameFree(frame)
+	s := testScaler(b, threads)
+	defer s.Free()
+	dst := avutil.AvFrameAlloc()
+	defer avutil.AvFrameFree(dst)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.Scale(dst, frame); err != nil {
+			b.Fatalf("Scale() failed: %#v", err)
+		}
+	}
+}
+
+func BenchmarkScaleSingleThread(b *testing.B) {
+	benchmarkScale(b, 1)
+}
+
+func BenchmarkScaleThreads(b *testing.B) {
+	benchmarkScale(b, runtime.NumCPU())
+}
@@ -26,6 +26,40 @@ type (
 	PixelFormat C.enum_AVPixelFormat
 )
 
+const (
+	SWS_FAST_BILINEAR = int(C.SWS_FAST_BILINEAR)
+	SWS_BILINEAR      = int(C.SWS_BILINEAR)
+	SWS_BICUBIC       = int(C.SWS_BICUBIC)
+	SWS_X             = int(C.SWS_X)
+	SWS_POINT         = int(C.SWS_POINT)
+	SWS_AREA          = int(C.SWS_AREA)
+	SWS_BICUBLIN      = int(C.SWS_BICUBLIN)
+	SWS_GAUSS         = int(C.SWS_GAUSS)
+	SWS_SINC          = int(C.SWS_SINC)
+	SWS_LANCZOS       = int(C.SWS_LANCZOS)
+	SWS_SPLINE        = int(C.SWS_SPLINE)
+
+	SWS_PRINT_INFO      = int(C.SWS_PRINT_INFO)
+	SWS_FULL_CHR_H_INT  = int(C.SWS_FULL_CHR_H_INT)
+	SWS_FULL_CHR_H_INP  = int(C.SWS_FULL_CHR_H_INP)
+	SWS_DIRECT_BGR      = int(C.SWS_DIRECT_BGR)
+	SWS_ACCURATE_RND    = int(C.SWS_ACCURATE_RND)
+	SWS_BITEXACT        = int(C.SWS_BITEXACT)
+	SWS_ERROR_DIFFUSION = int(C.SWS_ERROR_DIFFUSION)
+)
+
+//Colorspaces accepted by SwsGetcoefficients(), they match the AVCOL_SPC_* values of the same name.
+const (
+	SWS_CS_ITU709    = int(C.SWS_CS_ITU709)
+	SWS_CS_FCC       = int(C.SWS_CS_FCC)
+	SWS_CS_ITU601    = int(C.SWS_CS_ITU601)
+	SWS_CS_ITU624    = int(C.SWS_CS_ITU624)
+	SWS_CS_SMPTE170M = int(C.SWS_CS_SMPTE170M)
+	SWS_CS_SMPTE240M = int(C.SWS_CS_SMPTE240M)
+	SWS_CS_DEFAULT   = int(C.SWS_CS_DEFAULT)
+	SWS_CS_BT2020    = int(C.SWS_CS_BT2020)
+)
+
 //Return the LIBSWSCALE_VERSION_INT constant.
 func SwscaleVersion() uint {
 	return uint(C.swscale_version())